@@ -1,19 +1,28 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gopxl/beep"
 	"github.com/gopxl/beep/speaker"
 	"github.com/gopxl/beep/wav"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Collision"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Compensation"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/Converter"
-	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Motion"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Optimizer"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreFormat"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Timeline"
 	"github.com/zserge/lorca"
 )
 
@@ -32,8 +41,26 @@ func proc(n int, format *beep.Format, buffer *beep.Buffer, pos int) int {
 }
 
 func main() {
+	hand := flag.String("hand", "parity", fmt.Sprintf("手の振り分け方 %v", ScoreSingleHand.HandAssignerNames()))
+	profilePath := flag.String("profile", "", "腕の動作特性の YAML ファイル (省略時は既定値)")
+	tips := flag.Int("tips", 0, "1本の腕に載せたソレノイドの数 (1レーン間隔、0 はプロファイルの設定)")
+	offset := flag.Int("offset", 0, "譜面のオフセットに加える機器ごとの補正 (ミリ秒)")
+	parkingName := flag.String("parking", "edge", fmt.Sprintf("ノーツの間の腕の待たせ方 %v", Converter.ParkingNames()))
+	breakMs := flag.Int("break", Converter.DefaultBreakMs, "次のノーツまでこの時間 (ミリ秒) 以上空けば区切りとして -parking の待たせ方をする")
+	strict := flag.Bool("strict", false, "変換できないノーツの並びがあれば警告せずに止める")
+	audioPath := flag.String("audio", "", "再生する WAV ファイル (省略時は譜面と同じ名前の .wav)")
+	flag.Parse()
+
+	path := "star.txt"
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
+	}
+	if *audioPath == "" {
+		*audioPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".wav"
+	}
+
 	// Set up the audio
-	f, err := os.Open("S:\\git\\auto-sl-stage-tool\\star.wav")
+	f, err := os.Open(*audioPath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -56,20 +83,60 @@ func main() {
 	speaker.Play(ctrl)
 
 	// Set up the Score
-	score, err := ScoreDeleste.ParseScore("S:\\git\\auto-sl-stage-tool\\star.txt")
+	score, err := ScoreFormat.Open(path)
+	if err != nil {
+		panic(err)
+	}
+	profile := ArmProfile.Default()
+	if *profilePath != "" {
+		profile, err = ArmProfile.LoadFile(*profilePath)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if *tips > 0 {
+		profile.Arms.Left = CommandArm.NewArm(*tips)
+		profile.Arms.Right = CommandArm.NewArm(*tips)
+	}
+	assigner, err := ScoreSingleHand.NewHandAssigner(*hand)
 	if err != nil {
 		panic(err)
 	}
-	scoreLeft, scoreRight, err := ScoreSingleHand.ConvertFromDeleste(score)
+	parking, err := Converter.NewParking(*parkingName)
 	if err != nil {
 		panic(err)
 	}
-	cmdLeft, cmdRight, err := Converter.ConvertToCommands(scoreLeft, scoreRight, 178.0, 0)
+	// コマンドの時刻は音源ファイルの再生位置に合わせてある
+	result, err := Converter.ConvertScore(score, Converter.Options{Profile: profile, Assigner: assigner, Parking: parking, BreakMs: *breakMs, OffsetMs: *offset, Lenient: !*strict})
 	if err != nil {
 		panic(err)
 	}
+	for _, w := range result.Warnings {
+		fmt.Println("Warning:", w)
+	}
+	cmdLeft := Optimizer.Optimize(result.Left)
+	cmdRight := Optimizer.Optimize(result.Right)
+	// 移動の出発時刻と到着時刻を腕の速度・加速度から決める
+	cmdLeft, overloadsLeft := Motion.Plan(cmdLeft, profile)
+	cmdRight, overloadsRight := Motion.Plan(cmdRight, profile)
+	for _, o := range append(overloadsLeft, overloadsRight...) {
+		fmt.Println("Overload:", o)
+	}
+	cmdLeft, cmdRight, err = Collision.Plan(cmdLeft, cmdRight, profile)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	// 腕に送るのは遅れを補正した時刻で、表示は補正を戻して動作させたい時刻で行う
+	cmdLeft = Compensation.Apply(cmdLeft, profile)
+	cmdRight = Compensation.Apply(cmdRight, profile)
 	fmt.Println(cmdLeft)
 	fmt.Println(cmdRight)
+	cmdLeft = Compensation.Revert(cmdLeft, profile)
+	cmdRight = Compensation.Revert(cmdRight, profile)
+
+	// 腕の位置と押し状態は Timeline から求める
+	timeline := Timeline.New(cmdLeft, cmdRight, profile)
 
 	var indexLeft int = 0
 	var indexRight int = 0
@@ -218,94 +285,17 @@ func main() {
 			select {
 			case <-t.C:
 				currentPosition := (shot.Position()+offsetStreamShot)*1000/int(format.SampleRate) - offsetScore
-				if indexLeft < len(cmdLeft) && cmdLeft[indexLeft].TimeMs() <= currentPosition {
-					cmd := strings.Split(cmdLeft[indexLeft].Message(), " ")
-					switch {
-					case cmd[0] == "S" && len(cmd) == 4:
-						if cmd[3] == "ON" {
-							pushLeft = true
-						} else if cmd[3] == "OF" {
-							pushLeft = false
-						}
-					case cmd[0] == "M" && len(cmd) == 5:
-						switch cmd[3] {
-						case "LL":
-							positionLeft = -0.5
-						case "1L":
-							positionLeft = -0.2
-						case "1C":
-							positionLeft = 0
-						case "1R":
-							positionLeft = 0.2
-						case "2L":
-							positionLeft = 0.8
-						case "2C":
-							positionLeft = 1
-						case "2R":
-							positionLeft = 1.2
-						case "3L":
-							positionLeft = 1.8
-						case "3C":
-							positionLeft = 2
-						case "3R":
-							positionLeft = 2.2
-						case "4L":
-							positionLeft = 2.8
-						case "4C":
-							positionLeft = 3
-						case "4R":
-							positionLeft = 3
-						case "5L":
-							positionLeft = 3.8
-						case "5C":
-							positionLeft = 4
-						case "5R":
-							positionLeft = 4.2
-						case "RR":
-							positionLeft = 4.5
-						default:
-							panic("Unknown command: " + cmd[3])
-						}
-					default:
-						panic("Unknown command: " + cmd[0])
-					}
-
-					fmt.Println(cmdLeft[indexLeft])
+				state := timeline.StateAt(float64(currentPosition))
+				positionLeft = state.Left.Position - 1
+				positionRight = state.Right.Position - 1
+				pushLeft = state.Left.Pressed
+				pushRight = state.Right.Pressed
+				for indexLeft < len(cmdLeft) && cmdLeft[indexLeft].TimeMs() <= currentPosition {
+					fmt.Println(CommandArm.Describe(cmdLeft[indexLeft]))
 					indexLeft++
 				}
-				if indexRight < len(cmdRight) && cmdRight[indexRight].TimeMs() <= currentPosition {
-					cmd := strings.Split(cmdRight[indexRight].Message(), " ")
-					switch {
-					case cmd[0] == "S" && len(cmd) == 4:
-						if cmd[3] == "ON" {
-							pushRight = true
-						} else if cmd[3] == "OF" {
-							pushRight = false
-						}
-					case cmd[0] == "M" && len(cmd) == 5:
-						switch cmd[3] {
-						case "LL":
-							positionRight = -1
-						case "1L", "1C", "1R":
-							positionRight = 0
-						case "2L", "2C", "2R":
-							positionRight = 1
-						case "3L", "3C", "3R":
-							positionRight = 2
-						case "4L", "4C", "4R":
-							positionRight = 3
-						case "5L", "5C", "5R":
-							positionRight = 4
-						case "RR":
-							positionRight = 5
-						default:
-							panic("Unknown command: " + cmd[3])
-						}
-					default:
-						panic("Unknown command: " + cmd[0])
-					}
-
-					fmt.Println(cmdRight[indexRight])
+				for indexRight < len(cmdRight) && cmdRight[indexRight].TimeMs() <= currentPosition {
+					fmt.Println(CommandArm.Describe(cmdRight[indexRight]))
 					indexRight++
 				}
 			case <-startPause:
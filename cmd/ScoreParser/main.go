@@ -1,34 +1,110 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Collision"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Compensation"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/Converter"
-	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Feasibility"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Motion"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Optimizer"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreFormat"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
 )
 
 func main() {
-	score, err := ScoreDeleste.ParseScore("star.txt")
+	output := flag.String("o", "", "譜面を書き出すファイル (拡張子で形式を判定)")
+	hand := flag.String("hand", "parity", fmt.Sprintf("手の振り分け方 %v", ScoreSingleHand.HandAssignerNames()))
+	profilePath := flag.String("profile", "", "腕の動作特性の YAML ファイル (省略時は既定値)")
+	tips := flag.Int("tips", 0, "1本の腕に載せたソレノイドの数 (1レーン間隔、0 はプロファイルの設定)")
+	offset := flag.Int("offset", 0, "譜面のオフセットに加える機器ごとの補正 (ミリ秒)")
+	parkingName := flag.String("parking", "edge", fmt.Sprintf("ノーツの間の腕の待たせ方 %v", Converter.ParkingNames()))
+	breakMs := flag.Int("break", Converter.DefaultBreakMs, "次のノーツまでこの時間 (ミリ秒) 以上空けば区切りとして -parking の待たせ方をする")
+	explain := flag.Bool("explain", false, "ノーツごとに当てはめた変換の規則を表示する")
+	strict := flag.Bool("strict", false, "変換できないノーツの並びがあれば警告せずに止める")
+	flag.Parse()
+
+	path := "star.txt"
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
+	}
+
+	score, err := ScoreFormat.Open(path)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
 	fmt.Println(score)
+	fmt.Println("Hash:", score.Hash())
+
+	if *output != "" {
+		if err := ScoreFormat.Save(*output, score); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	profile := ArmProfile.Default()
+	if *profilePath != "" {
+		profile, err = ArmProfile.LoadFile(*profilePath)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	if *tips > 0 {
+		profile.Arms.Left = CommandArm.NewArm(*tips)
+		profile.Arms.Right = CommandArm.NewArm(*tips)
+	}
 
-	scoreLeft, scoreRight, err := ScoreSingleHand.ConvertFromDeleste(score)
+	assigner, err := ScoreSingleHand.NewHandAssigner(*hand)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
-	fmt.Println(scoreLeft)
-	fmt.Println(scoreRight)
-
-	cmdLeft, cmdRight, err := Converter.ConvertToCommands(scoreLeft, scoreRight, 120.0, 0)
+	parking, err := Converter.NewParking(*parkingName)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
-	fmt.Println(cmdLeft)
-	fmt.Println(cmdRight)
+	result, err := Converter.ConvertScore(score, Converter.Options{Profile: profile, Assigner: assigner, Parking: parking, BreakMs: *breakMs, OffsetMs: *offset, Lenient: !*strict, Explain: *explain})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if cost := result.Cost; cost != nil {
+		fmt.Printf("Cost: travel=%.1f lanes, peak=%.1f lanes/s, total=%.1f\n", cost.Travel, cost.PeakSpeed, cost.Total)
+	}
+	for _, w := range result.Warnings {
+		fmt.Println("Warning:", w)
+	}
+	// 変換直後のコマンドに対する注釈 (最適化・補正の前)
+	for _, e := range result.Explanations {
+		fmt.Println(e)
+	}
+	cmdLeft := Optimizer.Optimize(result.Left)
+	cmdRight := Optimizer.Optimize(result.Right)
+	// 移動の出発時刻と到着時刻を腕の速度・加速度から決める
+	cmdLeft, overloadsLeft := Motion.Plan(cmdLeft, profile)
+	cmdRight, overloadsRight := Motion.Plan(cmdRight, profile)
+	for _, o := range append(overloadsLeft, overloadsRight...) {
+		fmt.Println("Overload:", o)
+	}
+	cmdLeft, cmdRight, err = Collision.Plan(cmdLeft, cmdRight, profile)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	commands := Optimizer.MergeHands(cmdLeft, cmdRight)
+
+	violations := Feasibility.Check(commands, profile)
+	for _, v := range violations {
+		fmt.Println("Violation:", v)
+	}
+
+	// 腕に送るのは遅れを補正した時刻
+	fmt.Println(Compensation.Apply(commands, profile))
 }
@@ -0,0 +1,237 @@
+package ArmProfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+// Profile は腕の動作特性です
+// 腕の作りごとに値が違うため、YAML ファイルから読み込んで変換に渡します
+type Profile struct {
+	Speed         float64  `yaml:"speed"`        // 最高移動速度 (レーン/秒)
+	Acceleration  float64  `yaml:"acceleration"` // 加減速度 (レーン/秒^2)
+	Solenoid      Solenoid `yaml:"solenoid"`
+	Flick         Flick    `yaml:"flick"`
+	Home          Home     `yaml:"home"`
+	Arms          Arms     `yaml:"arms"`
+	Latency       Latency  `yaml:"latency"`
+	MinSeparation int      `yaml:"min_separation"` // 左腕の右端と右腕の左端の間に空ける最短距離 (CommandArm.Lane の刻み、3 で1レーン)
+}
+
+// Solenoid はソレノイドの応答と最短の押し・離し時間です
+type Solenoid struct {
+	OnLatencyMs  int `yaml:"on_latency_ms"`  // ON の指令から押し込むまでの遅れ (ミリ秒)
+	OffLatencyMs int `yaml:"off_latency_ms"` // OF の指令から離れるまでの遅れ (ミリ秒)
+	MinPressMs   int `yaml:"min_press_ms"`   // 押している最短時間 (ミリ秒)
+	MinOffMs     int `yaml:"min_off_ms"`     // 離してから次に押すまでの最短時間 (ミリ秒)
+}
+
+// Flick はフリックの動作 (押したまま横に払う) の設定です
+// 端末によって短すぎる・遅すぎるフリックが判定されないため、端末ごとに調整します
+type Flick struct {
+	Distance       int `yaml:"distance"`         // 払う距離 (CommandArm.Lane の刻み、1 で隣のサブレーン)
+	MinTravelMs    int `yaml:"min_travel_ms"`    // 払う動作にかける最短時間 (ミリ秒、0 で最高速)
+	ReleaseDelayMs int `yaml:"release_delay_ms"` // 払い終わってから離すまでの時間 (ミリ秒)
+}
+
+// Latency は指令から実際に動き出すまでの遅れを、腕・ソレノイドごとに測った値です
+type Latency struct {
+	Left  HandLatency `yaml:"left"`
+	Right HandLatency `yaml:"right"`
+}
+
+// HandLatency は1本の腕の遅れです
+// ソレノイドごとの値が無い場合は Solenoid の OnLatencyMs, OffLatencyMs を使います
+type HandLatency struct {
+	SolenoidOnMs  []int `yaml:"solenoid_on_ms"`  // ON の遅れ (ミリ秒、添字はソレノイドの番号)
+	SolenoidOffMs []int `yaml:"solenoid_off_ms"` // OF の遅れ (ミリ秒、添字はソレノイドの番号)
+	MoveMs        int   `yaml:"move_ms"`         // モーターが動き出すまでの遅れ (ミリ秒)
+}
+
+// Home は各腕の待機位置です
+type Home struct {
+	Left  CommandArm.Lane `yaml:"left"`
+	Right CommandArm.Lane `yaml:"right"`
+}
+
+// Arms は各腕のソレノイド配置です
+type Arms struct {
+	Left  CommandArm.Arm `yaml:"left"`
+	Right CommandArm.Arm `yaml:"right"`
+}
+
+// Default は標準的な腕のプロファイルを返します
+// 押している時間と払ってから離すまでの時間 (10ms) は従来の固定値と同じですが、
+// ノーツの前の移動は一律 300ms 前ではなく Speed と Acceleration から求めた移動時間だけ前に始まります
+func Default() Profile {
+	return Profile{
+		Speed:        10,
+		Acceleration: 100,
+		Solenoid: Solenoid{
+			OnLatencyMs:  0,
+			OffLatencyMs: 0,
+			MinPressMs:   10,
+			MinOffMs:     10,
+		},
+		Flick: Flick{
+			Distance:       1,
+			MinTravelMs:    0,
+			ReleaseDelayMs: 10,
+		},
+		Home: Home{
+			Left:  CommandArm.LeftEdge,
+			Right: CommandArm.RightEdge,
+		},
+		Arms: Arms{
+			Left:  CommandArm.SingleTip,
+			Right: CommandArm.SingleTip,
+		},
+		MinSeparation: 3,
+	}
+}
+
+// Load は YAML からプロファイルを読み込みます
+// 書かれていない項目は Default の値になり、知らない項目はエラーにします
+func Load(r io.Reader) (Profile, error) {
+	profile := Default()
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&profile); err != nil && !errors.Is(err, io.EOF) {
+		return Profile{}, err
+	}
+	if err := profile.Validate(); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// LoadFile は YAML ファイルからプロファイルを読み込みます
+func LoadFile(path string) (Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Validate は値が動作可能な範囲にあるかを確認します
+func (p Profile) Validate() error {
+	switch {
+	case p.Speed <= 0:
+		return fmt.Errorf("speed must be positive: %v", p.Speed)
+	case p.Acceleration <= 0:
+		return fmt.Errorf("acceleration must be positive: %v", p.Acceleration)
+	case p.Solenoid.OnLatencyMs < 0, p.Solenoid.OffLatencyMs < 0, p.Solenoid.MinPressMs < 0, p.Solenoid.MinOffMs < 0:
+		return fmt.Errorf("solenoid timings must not be negative: %+v", p.Solenoid)
+	case p.Flick.Distance < 1:
+		return fmt.Errorf("flick distance must be at least 1: %d", p.Flick.Distance)
+	case p.Flick.MinTravelMs < 0, p.Flick.ReleaseDelayMs < 0:
+		return fmt.Errorf("flick timings must not be negative: %+v", p.Flick)
+	case p.Latency.Left.MoveMs < 0, p.Latency.Right.MoveMs < 0:
+		return fmt.Errorf("move latency must not be negative: %+v", p.Latency)
+	case p.MinSeparation < 0:
+		return fmt.Errorf("min separation must not be negative: %d", p.MinSeparation)
+	}
+	for _, latencies := range [][]int{p.Latency.Left.SolenoidOnMs, p.Latency.Left.SolenoidOffMs, p.Latency.Right.SolenoidOnMs, p.Latency.Right.SolenoidOffMs} {
+		for _, latency := range latencies {
+			if latency < 0 {
+				return fmt.Errorf("solenoid latency must not be negative: %+v", p.Latency)
+			}
+		}
+	}
+	for _, arm := range []CommandArm.Arm{p.Arms.Left, p.Arms.Right} {
+		if len(arm.Offsets) == 0 || arm.Offsets[0] != 0 {
+			return fmt.Errorf("arm offsets must start with 0: %v", arm.Offsets)
+		}
+	}
+	return nil
+}
+
+// HomeOf は hand の待機位置を返します
+func (p Profile) HomeOf(hand CommandArm.Hand) CommandArm.Lane {
+	if hand == CommandArm.Left {
+		return p.Home.Left
+	}
+	return p.Home.Right
+}
+
+// ArmOf は hand のソレノイド配置を返します
+func (p Profile) ArmOf(hand CommandArm.Hand) CommandArm.Arm {
+	if hand == CommandArm.Left {
+		return p.Arms.Left
+	}
+	return p.Arms.Right
+}
+
+// SolenoidLatencyMs は hand の actuator 番目のソレノイドの ON (on が true) または OF の遅れを返します
+func (p Profile) SolenoidLatencyMs(hand CommandArm.Hand, actuator int, on bool) int {
+	latency := p.latencyOf(hand)
+	values, fallback := latency.SolenoidOffMs, p.Solenoid.OffLatencyMs
+	if on {
+		values, fallback = latency.SolenoidOnMs, p.Solenoid.OnLatencyMs
+	}
+	if actuator >= 0 && actuator < len(values) {
+		return values[actuator]
+	}
+	return fallback
+}
+
+// MoveLatencyMs は hand のモーターが動き出すまでの遅れを返します
+func (p Profile) MoveLatencyMs(hand CommandArm.Hand) int {
+	return p.latencyOf(hand).MoveMs
+}
+
+func (p Profile) latencyOf(hand CommandArm.Hand) HandLatency {
+	if hand == CommandArm.Left {
+		return p.Latency.Left
+	}
+	return p.Latency.Right
+}
+
+// LaneDistance は2つの位置の間の距離 (レーン) を返します (CommandArm.Lane の3刻みで1レーン)
+func LaneDistance(from, to CommandArm.Lane) float64 {
+	return math.Abs(float64(to-from)) / 3
+}
+
+// TravelMs は止まった状態から from から to へ移動して止まるまでの時間 (ミリ秒) を返します
+// 最高速度まで加速できない距離では三角形、それ以外は台形の速度変化とします
+func (p Profile) TravelMs(from, to CommandArm.Lane) float64 {
+	distance := LaneDistance(from, to)
+	accelDistance := p.Speed * p.Speed / p.Acceleration // 加速と減速に使う距離の合計
+	if distance < accelDistance {
+		return 2 * math.Sqrt(distance/p.Acceleration) * 1000
+	}
+	return (distance/p.Speed + p.Speed/p.Acceleration) * 1000
+}
+
+// TravelledAt は止まった状態から from から to へ動き始めて elapsedMs 後に進んだ距離 (レーン) を返します
+// TravelMs と同じ台形 (または三角形) の速度変化とします
+func (p Profile) TravelledAt(from, to CommandArm.Lane, elapsedMs float64) float64 {
+	distance := LaneDistance(from, to)
+	totalMs := p.TravelMs(from, to)
+	switch {
+	case elapsedMs <= 0:
+		return 0
+	case elapsedMs >= totalMs:
+		return distance
+	}
+	elapsed := elapsedMs / 1000
+	remaining := (totalMs - elapsedMs) / 1000
+	accelTime := math.Min(p.Speed/p.Acceleration, totalMs/2000) // 加速している時間
+	switch {
+	case elapsed < accelTime:
+		return p.Acceleration * elapsed * elapsed / 2
+	case remaining < accelTime:
+		return distance - p.Acceleration*remaining*remaining/2
+	default:
+		return p.Acceleration*accelTime*accelTime/2 + p.Speed*(elapsed-accelTime)
+	}
+}
@@ -0,0 +1,108 @@
+package ArmProfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("overrides defaults", func(t *testing.T) {
+		profile, err := Load(strings.NewReader(`
+speed: 8
+solenoid:
+  off_latency_ms: 15
+flick:
+  distance: 2
+home:
+  left: 1C
+arms:
+  right:
+    offsets: [0, 1]
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, 8.0, profile.Speed)
+		assert.Equal(t, 100.0, profile.Acceleration)
+		assert.Equal(t, 15, profile.Solenoid.OffLatencyMs)
+		assert.Equal(t, 10, profile.Solenoid.MinPressMs)
+		assert.Equal(t, 2, profile.Flick.Distance)
+		assert.Equal(t, 10, profile.Flick.ReleaseDelayMs)
+		assert.Equal(t, CommandArm.Lane1, profile.HomeOf(CommandArm.Left))
+		assert.Equal(t, CommandArm.RightEdge, profile.HomeOf(CommandArm.Right))
+		assert.Equal(t, CommandArm.SingleTip, profile.ArmOf(CommandArm.Left))
+		assert.Equal(t, CommandArm.DualTip, profile.ArmOf(CommandArm.Right))
+	})
+
+	t.Run("empty input is the default profile", func(t *testing.T) {
+		profile, err := Load(strings.NewReader(""))
+		assert.NoError(t, err)
+		assert.Equal(t, Default(), profile)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := Load(strings.NewReader("sped: 8\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects unknown lanes", func(t *testing.T) {
+		_, err := Load(strings.NewReader("home:\n  left: 6C\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		_, err := Load(strings.NewReader("speed: 0\n"))
+		assert.Error(t, err)
+		_, err = Load(strings.NewReader("flick:\n  distance: 0\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestTravelMs(t *testing.T) {
+	profile := Default()
+
+	// 1レーン以上は最高速度に達する (台形)
+	assert.InDelta(t, 200.0, profile.TravelMs(CommandArm.Lane1, CommandArm.Lane2), 1e-6)
+	assert.InDelta(t, 500.0, profile.TravelMs(CommandArm.Lane1, CommandArm.Lane5), 1e-6)
+	// 短い移動は加速しきらない (三角形)
+	assert.InDelta(t, 163.299, profile.TravelMs(CommandArm.Lane1, CommandArm.Lane1Right.Right()), 1e-3)
+	assert.Equal(t, 0.0, profile.TravelMs(CommandArm.Lane3, CommandArm.Lane3))
+}
+
+func TestLatency(t *testing.T) {
+	profile, err := Load(strings.NewReader(`
+solenoid:
+  on_latency_ms: 4
+  off_latency_ms: 2
+latency:
+  right:
+    solenoid_on_ms: [6, 9]
+    move_ms: 20
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, profile.SolenoidLatencyMs(CommandArm.Right, 0, true))
+	assert.Equal(t, 9, profile.SolenoidLatencyMs(CommandArm.Right, 1, true))
+	assert.Equal(t, 2, profile.SolenoidLatencyMs(CommandArm.Right, 1, false))
+	assert.Equal(t, 4, profile.SolenoidLatencyMs(CommandArm.Left, 0, true))
+	assert.Equal(t, 20, profile.MoveLatencyMs(CommandArm.Right))
+	assert.Equal(t, 0, profile.MoveLatencyMs(CommandArm.Left))
+
+	_, err = Load(strings.NewReader("latency:\n  left:\n    solenoid_off_ms: [-1]\n"))
+	assert.Error(t, err)
+}
+
+func TestTravelledAt(t *testing.T) {
+	profile := Default()
+
+	// 4レーン: 100ms 加速、300ms 等速、100ms 減速
+	assert.Equal(t, 0.0, profile.TravelledAt(CommandArm.Lane1, CommandArm.Lane5, 0))
+	assert.InDelta(t, 0.5, profile.TravelledAt(CommandArm.Lane1, CommandArm.Lane5, 100), 1e-9)
+	assert.InDelta(t, 2.0, profile.TravelledAt(CommandArm.Lane1, CommandArm.Lane5, 250), 1e-9)
+	assert.InDelta(t, 3.5, profile.TravelledAt(CommandArm.Lane1, CommandArm.Lane5, 400), 1e-9)
+	assert.Equal(t, 4.0, profile.TravelledAt(CommandArm.Lane1, CommandArm.Lane5, 600))
+	// 加速しきらない移動は半分の時間で半分進む
+	half := profile.TravelMs(CommandArm.Lane1, CommandArm.Lane1Right) / 2
+	assert.InDelta(t, 1.0/6, profile.TravelledAt(CommandArm.Lane1, CommandArm.Lane1Right, half), 1e-9)
+}
@@ -0,0 +1,284 @@
+package Collision
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Timeline"
+)
+
+// Conflict は左右の腕が最短距離より近づいてしまう箇所です
+type Conflict struct {
+	TimeMs int                // 近づき始める時刻 (ミリ秒)
+	Left   CommandArm.Lane    // そのときの左腕の右端
+	Right  CommandArm.Lane    // そのときの右腕の左端
+	Source *CommandArm.Source // 近づく原因になったコマンドの元のノーツ
+}
+
+func (c Conflict) String() string {
+	source := "unknown note"
+	if c.Source != nil {
+		source = c.Source.String()
+	}
+	return fmt.Sprintf("%d ms L %s R %s (%s)", c.TimeMs, c.Left, c.Right, source)
+}
+
+// CollisionError は回避できなかった衝突の一覧です
+type CollisionError struct {
+	Conflicts []Conflict
+}
+
+func (e *CollisionError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		conflicts[i] = c.String()
+	}
+	return fmt.Sprintf("%d arm collisions: %s", len(e.Conflicts), strings.Join(conflicts, "; "))
+}
+
+// Plan は左右のコマンド列を同じレール上の腕としてまとめて確認し、腕同士が近づきすぎないように調整します
+// 左腕は常に右腕より左にあり、その間を profile.MinSeparation 以上空けます
+// 近づく時間帯に片方の腕が押しておらず止まっている場合は、その腕を前もって退避させる移動を追加します
+// 退避した腕の次の移動は退避した位置から間に合うように動き始めを早め、次に動かずに押す場合は元の位置へ戻る移動を追加します
+// 回避できない箇所は CollisionError として返します (コマンドは回避できた分を反映して返します)
+func Plan(left, right []CommandArm.Command, profile ArmProfile.Profile) ([]CommandArm.Command, []CommandArm.Command, error) {
+	hands := [2][]CommandArm.Command{sortedCopy(left), sortedCopy(right)}
+	unavoidable := []Conflict{}
+	skipped := map[[2]float64]bool{} // 回避できなかった箇所 (左右の区間の開始時刻)
+
+	// 退避の移動を1つ追加するごとに最初から確認し直す
+	for range len(left) + len(right) + 1 {
+		inserted := false
+		timeline := Timeline.New(hands[0], hands[1], profile)
+		segments := [2][]segment{
+			buildSegments(timeline, CommandArm.Left, profile),
+			buildSegments(timeline, CommandArm.Right, profile),
+		}
+		forEachConflict(segments, profile, func(l, r segment, startMs, endMs float64) bool {
+			key := [2]float64{l.startMs, r.startMs}
+			if skipped[key] {
+				return true
+			}
+			if avoided, ok := avoid(&hands, l, r, startMs, endMs, profile); ok {
+				hands = avoided
+				inserted = true
+				return false
+			}
+			skipped[key] = true
+			source := r.source
+			if l.moving {
+				source = l.source
+			}
+			unavoidable = append(unavoidable, Conflict{
+				TimeMs: int(math.Floor(startMs)),
+				Left:   l.right(profile.Arms.Left),
+				Right:  r.left(),
+				Source: source,
+			})
+			return true
+		})
+		if !inserted {
+			break
+		}
+	}
+
+	if len(unavoidable) > 0 {
+		sort.SliceStable(unavoidable, func(i, j int) bool {
+			return unavoidable[i].TimeMs < unavoidable[j].TimeMs
+		})
+		return hands[0], hands[1], &CollisionError{Conflicts: unavoidable}
+	}
+	return hands[0], hands[1], nil
+}
+
+// segment は腕が止まっているか1回の移動をしている時間帯です
+type segment struct {
+	startMs, endMs float64
+	from, to       CommandArm.Lane // 止まっている場合は同じ位置
+	moving         bool
+	presses        [][2]float64 // この腕が押している時間帯 (全体)
+	source         *CommandArm.Source
+}
+
+// pressedDuring は startMs から endMs の間に押しているかを返します
+func (s segment) pressedDuring(startMs, endMs float64) bool {
+	for _, p := range s.presses {
+		if p[0] <= endMs && startMs <= p[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// span は時間帯の間に腕がいる範囲を返します
+func (s segment) span() (CommandArm.Lane, CommandArm.Lane) {
+	return min(s.from, s.to), max(s.from, s.to)
+}
+
+// right は左腕の右端 (最も右のソレノイドの位置) を返します
+func (s segment) right(arm CommandArm.Arm) CommandArm.Lane {
+	_, hi := s.span()
+	return hi + CommandArm.Lane(3*arm.Span())
+}
+
+// left は右腕の左端を返します
+func (s segment) left() CommandArm.Lane {
+	lo, _ := s.span()
+	return lo
+}
+
+// buildSegments は Timeline の片手の動きを、止まっている時間帯と移動している時間帯に分けます
+func buildSegments(timeline *Timeline.Timeline, hand CommandArm.Hand, profile ArmProfile.Profile) []segment {
+	segments := []segment{}
+	position := profile.HomeOf(hand)
+	sinceMs := math.Inf(-1)
+	var source *CommandArm.Source
+
+	for _, m := range timeline.Moves(hand) {
+		if m.StartMs > sinceMs {
+			segments = append(segments, segment{startMs: sinceMs, endMs: m.StartMs, from: position, to: position, source: source})
+		}
+		source = m.Source
+		if m.EndMs > m.StartMs {
+			segments = append(segments, segment{startMs: m.StartMs, endMs: m.EndMs, from: m.From, to: m.To, moving: true, source: source})
+		}
+		position = m.To
+		sinceMs = m.EndMs
+	}
+	segments = append(segments, segment{startMs: sinceMs, endMs: math.Inf(1), from: position, to: position, source: source})
+
+	pressed := [][2]float64{}
+	for _, p := range timeline.Presses(hand, math.Inf(-1), math.Inf(1)) {
+		pressed = append(pressed, [2]float64{p.StartMs, p.EndMs})
+	}
+	for i := range segments {
+		segments[i].presses = pressed
+	}
+	return segments
+}
+
+// forEachConflict は時間帯が重なっていて近づきすぎている左右の区間を時刻順に f に渡します
+// f が false を返すと打ち切ります
+func forEachConflict(segments [2][]segment, profile ArmProfile.Profile, f func(l, r segment, startMs, endMs float64) bool) {
+	type pair struct {
+		l, r           segment
+		startMs, endMs float64
+	}
+	pairs := []pair{}
+	for _, l := range segments[0] {
+		for _, r := range segments[1] {
+			startMs := math.Max(l.startMs, r.startMs)
+			endMs := math.Min(l.endMs, r.endMs)
+			if startMs >= endMs {
+				continue
+			}
+			if int(r.left()-l.right(profile.Arms.Left)) < profile.MinSeparation {
+				pairs = append(pairs, pair{l, r, startMs, endMs})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].startMs < pairs[j].startMs
+	})
+	for _, p := range pairs {
+		if !f(p.l, p.r, p.startMs, p.endMs) {
+			return
+		}
+	}
+}
+
+// avoid は止まっていて押していない方の腕を、重なる時間帯の前に離れた位置へ退避させます
+func avoid(hands *[2][]CommandArm.Command, l, r segment, startMs, endMs float64, profile ArmProfile.Profile) ([2][]CommandArm.Command, bool) {
+	type candidate struct {
+		hand   int
+		idle   segment
+		target CommandArm.Lane
+		reason *CommandArm.Source
+	}
+	candidates := []candidate{
+		{1, r, l.right(profile.Arms.Left) + CommandArm.Lane(profile.MinSeparation), l.source},
+		{0, l, r.left() - CommandArm.Lane(profile.MinSeparation) - CommandArm.Lane(3*profile.Arms.Left.Span()), r.source},
+	}
+	handOf := [2]CommandArm.Hand{CommandArm.Left, CommandArm.Right}
+
+	for _, c := range candidates {
+		if c.idle.moving || c.target < CommandArm.LeftEdge || c.target > CommandArm.RightEdge {
+			continue
+		}
+		// 重なり始めるまでに退避し終わるように動き始める
+		moveMs := startMs - math.Ceil(profile.TravelMs(c.idle.from, c.target)-1e-9)
+		if moveMs < c.idle.startMs || c.idle.pressedDuring(moveMs, endMs) {
+			continue
+		}
+
+		move := CommandArm.NewCommandMoveUntil(int(math.Floor(moveMs)), handOf[c.hand], c.target, int(math.Floor(startMs)))
+		if c.reason != nil {
+			move = CommandArm.WithSource(move, *c.reason)
+		}
+		commands, ok := leaveFrom(hands[c.hand], int(math.Floor(moveMs)), c.idle.from, c.target, endMs, profile)
+		if !ok {
+			continue
+		}
+		result := *hands
+		result[c.hand] = append(commands, move)
+		sort.SliceStable(result[c.hand], func(i, j int) bool {
+			return result[c.hand][i].TimeMs() < result[c.hand][j].TimeMs()
+		})
+		return result, true
+	}
+	return *hands, false
+}
+
+// leaveFrom は fromMs に from から target へ退避した腕が、次の動作に target から間に合うようにしたコマンド列を返します
+// 次の移動は到着時刻に間に合うように動き始めを早め、移動せずに押す場合は from へ戻る移動を追加します
+// 重なりが終わる untilMs より前に動き始めなければ間に合わない場合は false を返します
+func leaveFrom(commands []CommandArm.Command, fromMs int, from, target CommandArm.Lane, untilMs float64, profile ArmProfile.Profile) ([]CommandArm.Command, bool) {
+	result := append([]CommandArm.Command{}, commands...)
+	for i, command := range result {
+		if command.TimeMs() < fromMs {
+			continue
+		}
+		switch c := command.(type) {
+		case *CommandArm.CommandMove:
+			endMs, hasEnd := c.EndTime()
+			if !hasEnd {
+				return result, true
+			}
+			departureMs := endMs - int(math.Ceil(profile.TravelMs(target, c.Lane())-1e-9))
+			if departureMs >= c.TimeMs() {
+				return result, true
+			}
+			if float64(departureMs) < untilMs {
+				return nil, false
+			}
+			result[i] = CommandArm.Reschedule(c, departureMs, endMs)
+			return result, true
+		case *CommandArm.CommandSolenoid:
+			if !c.State() {
+				continue
+			}
+			departureMs := c.TimeMs() - int(math.Ceil(profile.TravelMs(target, from)-1e-9))
+			if float64(departureMs) < untilMs {
+				return nil, false
+			}
+			back := CommandArm.NewCommandMoveUntil(departureMs, c.Hand(), from, c.TimeMs())
+			if source := CommandArm.SourceOf(c); source != nil {
+				back = CommandArm.WithSource(back, *source)
+			}
+			return append(result, back), true
+		}
+	}
+	return result, true
+}
+
+func sortedCopy(commands []CommandArm.Command) []CommandArm.Command {
+	result := append([]CommandArm.Command{}, commands...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TimeMs() < result[j].TimeMs()
+	})
+	return result
+}
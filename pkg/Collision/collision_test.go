@@ -0,0 +1,147 @@
+package Collision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+func messages(commands []CommandArm.Command) []string {
+	result := []string{}
+	for _, c := range commands {
+		result = append(result, c.Message())
+	}
+	return result
+}
+
+func TestPlan(t *testing.T) {
+	profile := ArmProfile.Default()
+
+	t.Run("separated arms are unchanged", func(t *testing.T) {
+		left := []CommandArm.Command{
+			CommandArm.NewCommandMove(-164, CommandArm.Left, CommandArm.Lane1),
+			CommandArm.NewCommandSolenoid(0, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(10, CommandArm.Left, false),
+			CommandArm.NewCommandMove(10, CommandArm.Left, CommandArm.LeftEdge),
+		}
+		right := []CommandArm.Command{
+			CommandArm.NewCommandMove(-164, CommandArm.Right, CommandArm.Lane5),
+			CommandArm.NewCommandSolenoid(0, CommandArm.Right, true),
+			CommandArm.NewCommandSolenoid(10, CommandArm.Right, false),
+			CommandArm.NewCommandMove(10, CommandArm.Right, CommandArm.RightEdge),
+		}
+
+		plannedLeft, plannedRight, err := Plan(left, right, profile)
+		assert.NoError(t, err)
+		assert.Equal(t, messages(left), messages(plannedLeft))
+		assert.Equal(t, messages(right), messages(plannedRight))
+	})
+
+	t.Run("moves the idle arm out of the way", func(t *testing.T) {
+		left := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(500, CommandArm.Left, CommandArm.Lane3, 1000),
+			CommandArm.NewCommandSolenoid(1000, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(1010, CommandArm.Left, false),
+			CommandArm.NewCommandMove(1010, CommandArm.Left, CommandArm.LeftEdge),
+		}
+		right := []CommandArm.Command{
+			CommandArm.NewCommandMove(-367, CommandArm.Right, CommandArm.Lane3),
+			CommandArm.NewCommandSolenoid(0, CommandArm.Right, true),
+			CommandArm.NewCommandSolenoid(10, CommandArm.Right, false),
+			CommandArm.NewCommandMove(2000, CommandArm.Right, CommandArm.RightEdge),
+		}
+
+		plannedLeft, plannedRight, err := Plan(left, right, profile)
+		assert.NoError(t, err)
+		assert.Equal(t, messages(left), messages(plannedLeft))
+		assert.Equal(t, []string{
+			"M -367 R 3C",
+			"S 0 R ON",
+			"S 10 R OF",
+			"M 300 R 4C 500",
+			"M 2000 R RR",
+		}, messages(plannedRight))
+	})
+
+	t.Run("re-times the move after moving out of the way", func(t *testing.T) {
+		left := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(500, CommandArm.Left, CommandArm.Lane3, 1000),
+			CommandArm.NewCommandSolenoid(1000, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(1010, CommandArm.Left, false),
+			CommandArm.NewCommandMove(1010, CommandArm.Left, CommandArm.LeftEdge),
+		}
+		right := []CommandArm.Command{
+			CommandArm.NewCommandMove(-367, CommandArm.Right, CommandArm.Lane3),
+			CommandArm.NewCommandSolenoid(0, CommandArm.Right, true),
+			CommandArm.NewCommandSolenoid(10, CommandArm.Right, false),
+			// 3C から 2C へは 200ms だが、退避した 4C からは 300ms かかる
+			CommandArm.NewCommandMoveUntil(2300, CommandArm.Right, CommandArm.Lane2, 2500),
+			CommandArm.NewCommandSolenoid(2500, CommandArm.Right, true),
+		}
+
+		_, plannedRight, err := Plan(left, right, profile)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"M -367 R 3C",
+			"S 0 R ON",
+			"S 10 R OF",
+			"M 300 R 4C 500",
+			"M 2200 R 2C 2500",
+			"S 2500 R ON",
+		}, messages(plannedRight))
+	})
+
+	t.Run("returns before pressing at the lane it left", func(t *testing.T) {
+		left := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(500, CommandArm.Left, CommandArm.Lane3, 1000),
+			CommandArm.NewCommandSolenoid(1000, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(1010, CommandArm.Left, false),
+			CommandArm.NewCommandMove(1010, CommandArm.Left, CommandArm.LeftEdge),
+		}
+		right := []CommandArm.Command{
+			CommandArm.NewCommandMove(-367, CommandArm.Right, CommandArm.Lane3),
+			CommandArm.NewCommandSolenoid(0, CommandArm.Right, true),
+			CommandArm.NewCommandSolenoid(10, CommandArm.Right, false),
+			CommandArm.NewCommandSolenoid(2500, CommandArm.Right, true),
+		}
+
+		_, plannedRight, err := Plan(left, right, profile)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"M -367 R 3C",
+			"S 0 R ON",
+			"S 10 R OF",
+			"M 300 R 4C 500",
+			"M 2300 R 3C 2500",
+			"S 2500 R ON",
+		}, messages(plannedRight))
+	})
+
+	t.Run("reports collisions that cannot be avoided", func(t *testing.T) {
+		source := CommandArm.Source{Channel: 1, Measure: 0, Beat: 2, BeatSet: 4}
+		left := []CommandArm.Command{
+			CommandArm.WithSource(CommandArm.NewCommandMoveUntil(500, CommandArm.Left, CommandArm.Lane3, 1000), source),
+			CommandArm.NewCommandSolenoid(1000, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(1010, CommandArm.Left, false),
+		}
+		right := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(0, CommandArm.Right, CommandArm.Lane3, 990),
+			CommandArm.NewCommandSolenoid(990, CommandArm.Right, true),
+			CommandArm.NewCommandSolenoid(1100, CommandArm.Right, false),
+			CommandArm.NewCommandMove(1100, CommandArm.Right, CommandArm.RightEdge),
+		}
+
+		_, _, err := Plan(left, right, profile)
+		var collisionErr *CollisionError
+		assert.ErrorAs(t, err, &collisionErr)
+		assert.NotEmpty(t, collisionErr.Conflicts)
+		first := collisionErr.Conflicts[0]
+		assert.Equal(t, 500, first.TimeMs)
+		assert.Equal(t, CommandArm.Lane3, first.Left)
+		assert.Equal(t, CommandArm.Lane3, first.Right)
+		assert.Equal(t, &source, first.Source)
+	})
+}
@@ -0,0 +1,82 @@
+package CommandArm
+
+// Arm は1本の腕 (キャリッジ) に載ったソレノイドの配置です
+// 0 番目のソレノイドがキャリッジの基準で、移動コマンドのレーンはこの位置を指します
+type Arm struct {
+	Offsets []int `yaml:"offsets"` // 各ソレノイドの基準からのずれ (レーン単位、昇順で先頭は 0)
+}
+
+// SingleTip はソレノイドが1つだけの腕です
+var SingleTip = Arm{Offsets: []int{0}}
+
+// DualTip は1レーン間隔で2つのソレノイドを載せた腕です
+var DualTip = Arm{Offsets: []int{0, 1}}
+
+// NewArm は1レーン間隔で tips 個のソレノイドを載せた腕を作ります
+func NewArm(tips int) Arm {
+	if tips < 1 {
+		tips = 1
+	}
+	offsets := make([]int, tips)
+	for i := range offsets {
+		offsets[i] = i
+	}
+	return Arm{Offsets: offsets}
+}
+
+// Actuators はソレノイドの数を返します
+func (a Arm) Actuators() int {
+	if len(a.Offsets) == 0 {
+		return 1
+	}
+	return len(a.Offsets)
+}
+
+// Offset は actuator 番目のソレノイドの基準からのずれを返します
+func (a Arm) Offset(actuator int) int {
+	if actuator < 0 || actuator >= len(a.Offsets) {
+		return 0
+	}
+	return a.Offsets[actuator]
+}
+
+// Span は基準から最も右のソレノイドまでの距離 (レーン単位) を返します
+func (a Arm) Span() int {
+	span := 0
+	for _, offset := range a.Offsets {
+		span = max(span, offset)
+	}
+	return span
+}
+
+// Fit は同時に押す昇順のレーンにソレノイドを割り当て、各レーンを押すソレノイドの番号を返します
+// キャリッジを1か所に置いたまま全てのレーンにソレノイドが重ならない場合は false を返します
+func (a Arm) Fit(lanes []int) ([]int, bool) {
+	if len(lanes) == 0 || len(lanes) > a.Actuators() {
+		return nil, false
+	}
+	for first := 0; first < a.Actuators(); first++ {
+		carriage := lanes[0] - a.Offset(first)
+		actuators := make([]int, len(lanes))
+		used := map[int]bool{}
+		ok := true
+		for i, lane := range lanes {
+			actuators[i] = -1
+			for k := 0; k < a.Actuators(); k++ {
+				if !used[k] && carriage+a.Offset(k) == lane {
+					actuators[i] = k
+					used[k] = true
+					break
+				}
+			}
+			if actuators[i] < 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return actuators, true
+		}
+	}
+	return nil, false
+}
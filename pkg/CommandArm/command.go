@@ -1,12 +1,110 @@
 package CommandArm
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
 
 type Command interface {
 	TimeMs() int
 	Message() string
 }
 
+// Source はコマンドの元になったノーツです
+type Source struct {
+	Channel int // 元の譜面のチャンネル番号
+	Measure int // 小節数
+	Beat    int // 小節内の何拍目か
+	BeatSet int // 小節の分割数
+	Note    ScoreDeleste.NoteType
+	ID      string // 譜面の中でノーツを一意に表す ID (ScoreSingleHand.Note.ID)
+}
+
+func (s Source) String() string {
+	if s.ID != "" {
+		return fmt.Sprintf("ch%d %d:%d/%d %s [%s]", s.Channel, s.Measure, s.Beat, s.BeatSet, s.Note, s.ID)
+	}
+	return fmt.Sprintf("ch%d %d:%d/%d %s", s.Channel, s.Measure, s.Beat, s.BeatSet, s.Note)
+}
+
+// Describe はコマンドを元になったノーツと合わせて表します (ログ用)
+func Describe(c Command) string {
+	if source := SourceOf(c); source != nil {
+		return fmt.Sprintf("%s <- %s", c.Message(), source)
+	}
+	return c.Message()
+}
+
+// HandOf はコマンドの対象の手を返します
+func HandOf(c Command) Hand {
+	switch c := c.(type) {
+	case *CommandSolenoid:
+		return c.hand
+	case *CommandMove:
+		return c.hand
+	default:
+		return 0
+	}
+}
+
+// ShiftTime は時刻 (移動の到着時刻も含む) を deltaMs ずらしたコマンドを返します
+// 元のコマンドは変更しません
+func ShiftTime(c Command, deltaMs int) Command {
+	switch c := c.(type) {
+	case *CommandSolenoid:
+		shifted := *c
+		shifted.time += deltaMs
+		return &shifted
+	case *CommandMove:
+		shifted := *c
+		shifted.time += deltaMs
+		if shifted.hasEnd {
+			shifted.endTime += deltaMs
+		}
+		return &shifted
+	default:
+		return c
+	}
+}
+
+// Reschedule は移動の出発時刻と到着時刻を変えたコマンドを返します (移動以外はそのまま返します)
+// 元のコマンドは変更しません
+func Reschedule(c Command, timeMs, endTimeMs int) Command {
+	move, ok := c.(*CommandMove)
+	if !ok {
+		return c
+	}
+	rescheduled := *move
+	rescheduled.time = timeMs
+	rescheduled.endTime = endTimeMs
+	rescheduled.hasEnd = true
+	return &rescheduled
+}
+
+// SourceOf はコマンドの元になったノーツを返します (不明な場合は nil)
+func SourceOf(c Command) *Source {
+	switch c := c.(type) {
+	case *CommandSolenoid:
+		return c.source
+	case *CommandMove:
+		return c.source
+	default:
+		return nil
+	}
+}
+
+// WithSource はコマンドに元になったノーツを記録して返します
+func WithSource(c Command, source Source) Command {
+	switch c := c.(type) {
+	case *CommandSolenoid:
+		c.source = &source
+	case *CommandMove:
+		c.source = &source
+	}
+	return c
+}
+
 type Hand int
 
 const (
@@ -48,11 +146,17 @@ const (
 )
 
 func (l Lane) Left() Lane {
-	return l - 1
+	return l.Shift(-1)
 }
 
 func (l Lane) Right() Lane {
-	return l + 1
+	return l.Shift(1)
+}
+
+// Shift は n 刻み (負なら左、正なら右) ずらしたレーンを返します
+// レールの両端 (LeftEdge, RightEdge) を越えることはありません
+func (l Lane) Shift(n int) Lane {
+	return min(max(l+Lane(n), LeftEdge), RightEdge)
 }
 
 func (l Lane) String() string {
@@ -96,16 +200,59 @@ func (l Lane) String() string {
 	}
 }
 
+// Position はレーンをレーン単位の位置 (Lane1 が 1、Lane5 が 5) で返します
+func (l Lane) Position() float64 {
+	return float64(l-Lane1)/3 + 1
+}
+
+// ParseLane は String の表記 (LL, 1L, 1C, ... RR) からレーンを返します
+func ParseLane(s string) (Lane, error) {
+	for l := LeftEdge; l <= RightEdge; l++ {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown lane %q", s)
+}
+
+func (l Lane) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Lane) UnmarshalText(text []byte) error {
+	lane, err := ParseLane(string(text))
+	if err != nil {
+		return err
+	}
+	*l = lane
+	return nil
+}
+
 type CommandSolenoid struct {
-	time  int
-	hand  Hand
-	state bool
+	time     int
+	hand     Hand
+	actuator int // 腕の何番目のソレノイドか (0 が基準)
+	state    bool
+	source   *Source
 }
 
 func (c *CommandSolenoid) TimeMs() int {
 	return c.time
 }
 
+func (c *CommandSolenoid) Hand() Hand {
+	return c.hand
+}
+
+func (c *CommandSolenoid) Actuator() int {
+	return c.actuator
+}
+
+// State は押す (ON) なら true を返します
+func (c *CommandSolenoid) State() bool {
+	return c.state
+}
+
 func (c *CommandSolenoid) Message() string {
 	var state string
 	if c.state {
@@ -113,6 +260,10 @@ func (c *CommandSolenoid) Message() string {
 	} else {
 		state = "OF"
 	}
+	// 基準のソレノイドは従来どおり4項目で表し、それ以外はソレノイド番号を末尾に付けます
+	if c.actuator != 0 {
+		return fmt.Sprintf("S %d %s %s %d", c.time, c.hand, state, c.actuator)
+	}
 	return fmt.Sprintf("S %d %s %s", c.time, c.hand, state)
 }
 
@@ -125,13 +276,33 @@ type CommandMove struct {
 	hand    Hand
 	lane    Lane
 	endTime int
+	hasEnd  bool // 到着すべき時刻が指定されているか
+	source  *Source
 }
 
 func (c *CommandMove) TimeMs() int {
 	return c.time
 }
 
+func (c *CommandMove) Hand() Hand {
+	return c.hand
+}
+
+func (c *CommandMove) Lane() Lane {
+	return c.lane
+}
+
+// EndTime は到着すべき時刻と、それが指定されているかを返します
+func (c *CommandMove) EndTime() (int, bool) {
+	return c.endTime, c.hasEnd
+}
+
+// Message は到着すべき時刻が指定されていない移動を、その項目を省いた4項目で表します
+// (0 ミリ秒に到着すべき移動と区別するため)
 func (c *CommandMove) Message() string {
+	if !c.hasEnd {
+		return fmt.Sprintf("M %d %s %s", c.time, c.hand, c.lane)
+	}
 	return fmt.Sprintf("M %d %s %s %d", c.time, c.hand, c.lane, c.endTime)
 }
 
@@ -140,18 +311,35 @@ func (c *CommandMove) String() string {
 }
 
 func NewCommandSolenoid(time int, hand Hand, state bool) Command {
+	return NewCommandActuator(time, hand, 0, state)
+}
+
+// NewCommandActuator は腕の actuator 番目のソレノイドを操作するコマンドを作ります
+func NewCommandActuator(time int, hand Hand, actuator int, state bool) Command {
 	return &CommandSolenoid{
-		time:  time,
-		hand:  hand,
-		state: state,
+		time:     time,
+		hand:     hand,
+		actuator: actuator,
+		state:    state,
+	}
+}
+
+// NewCommandMove は到着すべき時刻を指定しない移動のコマンドを作ります
+func NewCommandMove(time int, hand Hand, lane Lane) Command {
+	return &CommandMove{
+		time: time,
+		hand: hand,
+		lane: lane,
 	}
 }
 
-func NewCommandMove(time int, hand Hand, lane Lane, endTime int) Command {
+// NewCommandMoveUntil は endTime までに到着すべき移動のコマンドを作ります
+func NewCommandMoveUntil(time int, hand Hand, lane Lane, endTime int) Command {
 	return &CommandMove{
 		time:    time,
 		hand:    hand,
 		lane:    lane,
 		endTime: endTime,
+		hasEnd:  true,
 	}
 }
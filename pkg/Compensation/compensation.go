@@ -0,0 +1,78 @@
+package Compensation
+
+import (
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+// Apply は各コマンドを対象のソレノイド・モーターの遅れの分だけ早めます
+// 変換後のコマンド列 (動作させたい時刻) を、腕に送る時刻の列にします
+// 結果は Sort の順に並べ直します
+func Apply(commands []CommandArm.Command, profile ArmProfile.Profile) []CommandArm.Command {
+	return shift(commands, profile, -1)
+}
+
+// Revert は Apply で早めた分を戻し、動作させたい時刻の列にします (シミュレーターの表示用)
+// Sort の順に並んだ列に Apply してから Revert すると元の列に戻ります
+func Revert(commands []CommandArm.Command, profile ArmProfile.Profile) []CommandArm.Command {
+	return shift(commands, profile, 1)
+}
+
+// Sort はコマンドを時刻順に並べ、同じ時刻ではリリース、プッシュ、移動の順、さらに手、ソレノイドの番号の順にします
+func Sort(commands []CommandArm.Command) {
+	sort.SliceStable(commands, func(i, j int) bool {
+		a, b := commands[i], commands[j]
+		if a.TimeMs() != b.TimeMs() {
+			return a.TimeMs() < b.TimeMs()
+		}
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if CommandArm.HandOf(a) != CommandArm.HandOf(b) {
+			return CommandArm.HandOf(a) < CommandArm.HandOf(b)
+		}
+		return actuatorOf(a) < actuatorOf(b)
+	})
+}
+
+func shift(commands []CommandArm.Command, profile ArmProfile.Profile, sign int) []CommandArm.Command {
+	result := make([]CommandArm.Command, len(commands))
+	for i, command := range commands {
+		result[i] = CommandArm.ShiftTime(command, sign*latencyMs(command, profile))
+	}
+	Sort(result)
+	return result
+}
+
+// latencyMs はコマンドの対象の遅れを返します
+func latencyMs(command CommandArm.Command, profile ArmProfile.Profile) int {
+	switch c := command.(type) {
+	case *CommandArm.CommandSolenoid:
+		return profile.SolenoidLatencyMs(c.Hand(), c.Actuator(), c.State())
+	case *CommandArm.CommandMove:
+		return profile.MoveLatencyMs(c.Hand())
+	default:
+		return 0
+	}
+}
+
+func actuatorOf(command CommandArm.Command) int {
+	if c, ok := command.(*CommandArm.CommandSolenoid); ok {
+		return c.Actuator()
+	}
+	return 0
+}
+
+func rank(command CommandArm.Command) int {
+	switch c := command.(type) {
+	case *CommandArm.CommandSolenoid:
+		if !c.State() {
+			return 0
+		}
+		return 1
+	default:
+		return 2
+	}
+}
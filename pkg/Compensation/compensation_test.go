@@ -0,0 +1,91 @@
+package Compensation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+func messages(commands []CommandArm.Command) []string {
+	result := []string{}
+	for _, c := range commands {
+		result = append(result, c.Message())
+	}
+	return result
+}
+
+func testProfile() ArmProfile.Profile {
+	profile := ArmProfile.Default()
+	profile.Solenoid.OnLatencyMs = 4
+	profile.Solenoid.OffLatencyMs = 2
+	profile.Latency.Right = ArmProfile.HandLatency{
+		SolenoidOnMs:  []int{6, 9},
+		SolenoidOffMs: []int{3},
+		MoveMs:        20,
+	}
+	return profile
+}
+
+func TestApply(t *testing.T) {
+	commands := []CommandArm.Command{
+		CommandArm.NewCommandMove(-367, CommandArm.Right, CommandArm.Lane3),
+		CommandArm.NewCommandSolenoid(0, CommandArm.Left, true),
+		CommandArm.NewCommandActuator(0, CommandArm.Right, 0, true),
+		CommandArm.NewCommandActuator(0, CommandArm.Right, 1, true),
+		CommandArm.NewCommandSolenoid(10, CommandArm.Left, false),
+		CommandArm.NewCommandActuator(10, CommandArm.Right, 0, false),
+		CommandArm.NewCommandActuator(10, CommandArm.Right, 1, false),
+		CommandArm.NewCommandMoveUntil(10, CommandArm.Right, CommandArm.Lane4, 500),
+	}
+
+	compensated := Apply(commands, testProfile())
+	assert.Equal(t, []string{
+		"M -387 R 3C",
+		"M -10 R 4C 480",
+		"S -9 R ON 1",
+		"S -6 R ON",
+		"S -4 L ON",
+		"S 7 R OF",
+		"S 8 L OF",
+		"S 8 R OF 1",
+	}, messages(compensated))
+}
+
+func TestRevert(t *testing.T) {
+	commands := []CommandArm.Command{
+		CommandArm.NewCommandMove(-367, CommandArm.Right, CommandArm.Lane3),
+		CommandArm.NewCommandSolenoid(0, CommandArm.Left, true),
+		CommandArm.NewCommandActuator(0, CommandArm.Right, 0, true),
+		CommandArm.NewCommandActuator(0, CommandArm.Right, 1, true),
+		CommandArm.NewCommandSolenoid(10, CommandArm.Left, false),
+		CommandArm.NewCommandActuator(10, CommandArm.Right, 0, false),
+		CommandArm.NewCommandActuator(10, CommandArm.Right, 1, false),
+		CommandArm.NewCommandMoveUntil(10, CommandArm.Right, CommandArm.Lane4, 500),
+	}
+	source := CommandArm.Source{Channel: 3, Measure: 1, Beat: 0, BeatSet: 4}
+	commands[2] = CommandArm.WithSource(commands[2], source)
+
+	restored := Revert(Apply(commands, testProfile()), testProfile())
+	assert.Equal(t, messages(commands), messages(restored))
+	assert.Equal(t, &source, CommandArm.SourceOf(restored[2]))
+}
+
+func TestApplyDeadlineAtZero(t *testing.T) {
+	// 音源の先頭で押すノーツに向かう移動は 0 ms が到着期限になる
+	commands := []CommandArm.Command{
+		CommandArm.NewCommandMoveUntil(-200, CommandArm.Right, CommandArm.Lane3, 0),
+		CommandArm.NewCommandActuator(0, CommandArm.Right, 0, true),
+	}
+
+	compensated := Apply(commands, testProfile())
+	assert.Equal(t, []string{"M -220 R 3C -20", "S -6 R ON"}, messages(compensated))
+
+	restored := Revert(compensated, testProfile())
+	assert.Equal(t, messages(commands), messages(restored))
+	endMs, hasEnd := restored[0].(*CommandArm.CommandMove).EndTime()
+	assert.Equal(t, 0, endMs)
+	assert.True(t, hasEnd)
+}
@@ -1,6 +1,10 @@
 package Converter
 
 import (
+	"fmt"
+	"math"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
@@ -10,127 +14,296 @@ import (
 // 1つ目が左、2つ目が右
 // bpm: 曲のテンポ (BPM)
 // offset: 曲の開始オフセット (ミリ秒)
+// 譜面のヘッダーとテンポ変化から時刻を求める場合は ConvertScore を使います
 func ConvertToCommands(leftHand []ScoreSingleHand.Note, rightHand []ScoreSingleHand.Note, bpm float64, offset int) ([]CommandArm.Command, []CommandArm.Command, error) {
-	left := []CommandArm.Command{}
-	right := []CommandArm.Command{}
+	return ConvertToCommandsWithProfile(leftHand, rightHand, ArmProfile.Default(), bpm, offset)
+}
+
+// ConvertToCommandsWithProfile は ConvertToCommands と同じですが、腕の動作特性を指定します
+// 移動コマンドは各ノーツの Actuator のソレノイドがそのレーンに重なる位置へキャリッジを動かします
+// 変換の規則で扱えないノーツの並びは ConversionError として返します (コマンドは変換できた分を返します)
+func ConvertToCommandsWithProfile(leftHand []ScoreSingleHand.Note, rightHand []ScoreSingleHand.Note, profile ArmProfile.Profile, bpm float64, offset int) ([]CommandArm.Command, []CommandArm.Command, error) {
+	result := convertHands(leftHand, rightHand, profile, fixedTempo(bpm, offset), DefaultParking, DefaultBreakMs, false)
+	if len(result.Warnings) > 0 {
+		return result.Left, result.Right, &ConversionError{Issues: result.Warnings}
+	}
+	return result.Left, result.Right, nil
+}
+
+// convertHands は左右のノーツをそれぞれ timeOf の時刻でコマンドに変換し、扱えなかった並びを左手、右手の順に Warnings に入れます
+// ノーツの間に腕を待たせる位置は parking で決め、次のノーツまで breakMs 以上空けば区切りとします
+// explain の場合は当てはめた規則を Explanations に左手、右手の順に記録します
+func convertHands(leftHand []ScoreSingleHand.Note, rightHand []ScoreSingleHand.Note, profile ArmProfile.Profile, timeOf noteTime, parking Parking, breakMs int, explain bool) Result {
+	result := Result{Left: []CommandArm.Command{}, Right: []CommandArm.Command{}, Warnings: []Issue{}}
+	leftVisits := visitsOf(leftHand, CommandArm.Left, profile, timeOf)
+	rightVisits := visitsOf(rightHand, CommandArm.Right, profile, timeOf)
+	leftExplainer := newExplainer(explain, CommandArm.Left)
+	rightExplainer := newExplainer(explain, CommandArm.Right)
 
 	// 左手のコマンドを生成
-	leftCommands := generateHandCommands(leftHand, CommandArm.Left, bpm, offset)
-	left = append(left, leftCommands...)
+	leftCommands := generateHand(leftHand, CommandArm.Left, profile, timeOf, parking, breakMs, rightVisits, leftExplainer)
+	result.Left = append(result.Left, leftCommands...)
+	result.Warnings = append(result.Warnings, checkHand(leftHand, CommandArm.Left)...)
 
 	// 右手のコマンドを生成
-	rightCommands := generateHandCommands(rightHand, CommandArm.Right, bpm, offset)
-	right = append(right, rightCommands...)
+	rightCommands := generateHand(rightHand, CommandArm.Right, profile, timeOf, parking, breakMs, leftVisits, rightExplainer)
+	result.Right = append(result.Right, rightCommands...)
+	result.Warnings = append(result.Warnings, checkHand(rightHand, CommandArm.Right)...)
 
-	return left, right, nil
+	result.Explanations = append(leftExplainer.result(), rightExplainer.result()...)
+	return result
 }
 
 // generateHandCommands は片手分のコマンドを生成します
-func generateHandCommands(notes []ScoreSingleHand.Note, hand CommandArm.Hand, bpm float64, offset int) []CommandArm.Command {
-	commands := []CommandArm.Command{}
+// ロング・スライドは ScoreSingleHand.BuildActions で始点から終点までまとめてから処理し、
+// スライドは押したまま中継点を移動して、終点でリリースするかフリックします
+// 同時刻の単独ノーツ (複数ソレノイドの腕での同時押し) は1回の移動でまとめて押します
+// 続くフリックへは、その向きに払える位置にいれば押したままつなぎます (flickContinues)
+// 離した後は DefaultParking で決めた位置で待ち (次のノーツまで DefaultBreakMs 以上空けば区切り)、そこが次のノーツの位置でなければ移動にかかる時間だけ前に動き始めます
+// 同じ時刻のコマンドはリリース、移動の順に並べます (押したまま動かない)
+// ノーツの時刻 (ミリ秒) は noteTimeMs で求めます
+func generateHandCommands(notes []ScoreSingleHand.Note, hand CommandArm.Hand, profile ArmProfile.Profile, noteTimeMs noteTime) []CommandArm.Command {
+	return generateHand(notes, hand, profile, noteTimeMs, DefaultParking, DefaultBreakMs, nil, nil)
+}
 
-	// 1小節あたりの時間（ミリ秒）
-	measureTimeMs := 60000.0 * 4.0 / bpm
+// generateHand は generateHandCommands と同じですが、待たせ方と区切りとみなす待ち時間、もう一方の手がノーツを押す位置 other を指定します
+// explain が nil でなければ、動作ごとに当てはめた規則を記録します
+func generateHand(notes []ScoreSingleHand.Note, hand CommandArm.Hand, profile ArmProfile.Profile, noteTimeMs noteTime, parking Parking, breakMs int, other []visit, explain *explainer) []CommandArm.Command {
+	commands := []CommandArm.Command{}
+	arm := profile.ArmOf(hand)
+	flick := profile.Flick
+	home := profile.HomeOf(hand)
+	otherHome := profile.HomeOf(CommandArm.Right)
+	if hand == CommandArm.Right {
+		otherHome = profile.HomeOf(CommandArm.Left)
+	}
 
-	for i, note := range notes {
-		isFirstNote := i == 0
-		isLastNote := i == len(notes)-1
+	var current ScoreSingleHand.Note // 今処理しているノーツ (コマンドの Source になる)
+	emit := func(rule Rule, command CommandArm.Command, detail string) {
+		command = CommandArm.WithSource(command, sourceOf(current))
+		commands = append(commands, command)
+		explain.fired(rule, command, detail)
+	}
 
-		// ノートの時間（ミリ秒）を計算
-		// 小節数 * 拍子 + 拍数 で全体の拍数を計算し、それに1拍の時間をかける
-		timeMs := int(measureTimeMs*float64(note.Measure)+measureTimeMs*float64(note.Beat)/float64(note.BeatSet)) + offset
-		// timeMs := int((float64(note.Measure*note.BeatSet+note.Beat) * beatTimeMs)) + offset
+	// ノーツを Actuator のソレノイドで押すときのキャリッジの位置
+	carriageLane := func(note ScoreSingleHand.Note) CommandArm.Lane {
+		return convertTargetPosToLane(note.TargetPos - arm.Offset(note.Actuator))
+	}
 
-		// TargetPos から Lane を決定
-		lane := convertTargetPosToLane(note.TargetPos)
+	steps := groupSteps(ScoreSingleHand.BuildActions(notes))
+	pressed := map[int]bool{} // 押しているソレノイド
+	press := func(timeMs int, actuator int) {
+		if pressed[actuator] {
+			explain.skipped(RulePress, "actuator %d already pressed", actuator)
+			return
+		}
+		emit(RulePress, CommandArm.NewCommandActuator(timeMs, hand, actuator, true), "")
+		pressed[actuator] = true
+	}
+	releaseAll := func(rule Rule, timeMs int) {
+		if len(pressed) == 0 {
+			explain.skipped(rule, "nothing pressed")
+		}
+		for actuator := 0; actuator < arm.Actuators(); actuator++ {
+			if pressed[actuator] {
+				emit(rule, CommandArm.NewCommandActuator(timeMs, hand, actuator, false), "")
+				delete(pressed, actuator)
+			}
+		}
+	}
 
-		currentNote := note
-		var nextNote *ScoreSingleHand.Note = nil
-		var lastNote *ScoreSingleHand.Note = nil
-		if !isLastNote {
-			nextNote = &notes[i+1]
+	at := home       // 次のノーツの前にキャリッジが待っている位置
+	chained := false // 前のフリックから押したままつないでいるか
+	for i, step := range steps {
+		if step[0].IsGap() {
+			continue
 		}
-		if !isFirstNote {
-			lastNote = &notes[i-1]
+		var nextStep []ScoreSingleHand.Action = nil
+		if i < len(steps)-1 {
+			nextStep = steps[i+1]
 		}
+		action := step[0]
+		current = action.Note
+		explain.begin(step)
+
+		timeMs := noteTimeMs(action.Note)
+		lane := carriageLane(action.Note)
 
-		// 実処理
-		// 前段移動
-		if lastNote == nil || lastNote.Note == ScoreDeleste.None {
-			moveCmd := CommandArm.NewCommandMove(
-				timeMs-300,
-				hand,
-				lane,
-				timeMs,
-			)
-			commands = append(commands, moveCmd)
+		// 前段移動 (待っていた位置から、押す時刻に着くように動く)
+		if at != lane {
+			leadMs := int(math.Ceil(profile.TravelMs(at, lane) - 1e-9))
+			emit(RulePreMove, CommandArm.NewCommandMoveUntil(timeMs-leadMs, hand, lane, timeMs), fmt.Sprintf("from %s, %d ms ahead", at, leadMs))
+		} else if chained {
+			explain.skipped(RulePreMove, "held through from the previous flick")
+		} else {
+			explain.skipped(RulePreMove, "already waiting at %s", lane)
 		}
+		chained = false
 
 		// 本番移動・プッシュ・リリース
-		if lastNote != nil && lastNote.Note != ScoreDeleste.None && lastNote.Note == ScoreDeleste.LongStart {
-			if currentNote.Note == ScoreDeleste.Tap {
-				releaseCmd := CommandArm.NewCommandSolenoid(timeMs, hand, false)
-				commands = append(commands, releaseCmd)
-			} else if currentNote.Note == ScoreDeleste.LeftFlick {
-				moveCmd := CommandArm.NewCommandMove(timeMs, hand, lane.Left(), 0)
-				commands = append(commands, moveCmd)
-			} else if currentNote.Note == ScoreDeleste.RightFlick {
-				moveCmd := CommandArm.NewCommandMove(timeMs, hand, lane.Right(), 0)
-				commands = append(commands, moveCmd)
+		endTimeMs := timeMs
+		releaseMs := timeMs + profile.Solenoid.MinPressMs
+		position := lane // 動作を終えたときのキャリッジの位置
+		switch {
+		case action.Hold != nil:
+			for _, inner := range action.Hold.Inside {
+				if !inner.IsGap() {
+					explain.skipped(RuleUnsupported, "%s inside the hold is not pressed", sourceOf(inner.Note))
+				}
 			}
-		} else {
-			if (lastNote != nil && lastNote.Note == ScoreDeleste.Tap) || (lastNote == nil || lastNote.Note == ScoreDeleste.None) {
-				if currentNote.Note == ScoreDeleste.LeftFlick || currentNote.Note == ScoreDeleste.RightFlick {
-					if nextNote != nil && (nextNote.Note == ScoreDeleste.LeftFlick || nextNote.Note == ScoreDeleste.RightFlick) {
-						releaseCmd := CommandArm.NewCommandSolenoid(timeMs, hand, true)
-						commands = append(commands, releaseCmd)
-					}
+			press(timeMs, action.Note.Actuator)
+			if action.Hold.IsSlide() {
+				// スライドは押したまま中継点を順にたどり、各点の時刻に着くように動かす
+				fromMs := timeMs
+				for _, point := range action.Hold.Points()[1:] {
+					current = point
+					pointMs := noteTimeMs(point)
+					emit(RuleSlideRelay, CommandArm.NewCommandMoveUntil(fromMs, hand, carriageLane(point), pointMs), "")
+					fromMs = pointMs
 				}
 			}
-			if currentNote.Note == ScoreDeleste.Tap || currentNote.Note == ScoreDeleste.LongStart {
-				pressCmd := CommandArm.NewCommandSolenoid(timeMs, hand, true)
-				commands = append(commands, pressCmd)
-			} else if currentNote.Note == ScoreDeleste.LeftFlick {
-				moveCmd := CommandArm.NewCommandMove(timeMs, hand, lane.Left(), 0)
-				commands = append(commands, moveCmd)
-			} else if currentNote.Note == ScoreDeleste.RightFlick {
-				moveCmd := CommandArm.NewCommandMove(timeMs, hand, lane.Right(), 0)
-				commands = append(commands, moveCmd)
+			current = action.Hold.End
+			endTimeMs = noteTimeMs(action.Hold.End)
+			position = carriageLane(action.Hold.End)
+			if direction := flickDirection(action.Hold.End.Note); direction != 0 {
+				emit(RuleHoldEndByFlick, flickMove(flick, endTimeMs, hand, position, direction), "")
+				position = flickTarget(flick, position, direction)
+				releaseMs = endTimeMs + flickReleaseAfterMs(flick)
+			} else {
+				releaseAll(RuleHoldEndByTap, endTimeMs)
+				releaseMs = endTimeMs
+			}
+		case action.Note.Note == ScoreDeleste.Tap, isFlick(action.Note.Note):
+			for _, a := range step {
+				press(timeMs, a.Note.Actuator)
 			}
+			if direction := flickDirection(action.Note.Note); direction != 0 {
+				emit(RuleFlick, flickMove(flick, timeMs, hand, lane, direction), "")
+				position = flickTarget(flick, lane, direction)
+				releaseMs = timeMs + flickReleaseAfterMs(flick)
+			}
+		default:
+			explain.skipped(RuleUnsupported, "%s is not handled on its own", action.Note.Note)
+			continue
 		}
 
 		// 後段
-		if currentNote.Note == ScoreDeleste.Tap || currentNote.Note == ScoreDeleste.LeftFlick || currentNote.Note == ScoreDeleste.RightFlick {
-			// リリース
-			if nextNote != nil && nextNote.Note != ScoreDeleste.None && (nextNote.Note == ScoreDeleste.LeftFlick || nextNote.Note == ScoreDeleste.RightFlick) {
-				// なにもしない
-			} else if lastNote != nil && lastNote.Note != ScoreDeleste.None && lastNote.Note == ScoreDeleste.LongStart && currentNote.Note == ScoreDeleste.Tap {
-				// なにもしない
-			} else {
-				releaseCmd := CommandArm.NewCommandSolenoid(timeMs+10, hand, false)
-				commands = append(commands, releaseCmd)
-			}
+		// 次のフリックへ押したままつなぐ
+		switch {
+		case len(pressed) == 0:
+			explain.skipped(RuleKeepPressed, "already released")
+		case nextStep == nil:
+			explain.skipped(RuleKeepPressed, "last note")
+		case nextStep[0].IsGap():
+			explain.skipped(RuleKeepPressed, "next is a gap")
+		case nextStep[0].Hold != nil:
+			explain.skipped(RuleKeepPressed, "next is a hold")
+		case flickDirection(nextStep[0].Note.Note) == 0:
+			explain.skipped(RuleKeepPressed, "next is a %s", nextStep[0].Note.Note)
+		case !flickContinues(flick, position, carriageLane(nextStep[0].Note), flickDirection(nextStep[0].Note.Note)):
+			explain.skipped(RuleKeepPressed, "next %s at %s cannot continue from %s", nextStep[0].Note.Note, carriageLane(nextStep[0].Note), position)
+		default:
+			explain.fired(RuleKeepPressed, nil, fmt.Sprintf("next %s at %s", nextStep[0].Note.Note, carriageLane(nextStep[0].Note)))
+			at = carriageLane(nextStep[0].Note)
+			chained = true
+			continue
+		}
 
-			// 移動
-			if nextNote == nil || nextNote.Note == ScoreDeleste.None {
-				var side CommandArm.Lane
-				if hand == CommandArm.Left {
-					side = CommandArm.LeftEdge
-				} else {
-					side = CommandArm.RightEdge
-				}
-				moveCmd := CommandArm.NewCommandMove(timeMs+10, hand, side, 0)
-				commands = append(commands, moveCmd)
-			} else {
-				moveCmd := CommandArm.NewCommandMove(timeMs+10, hand, convertTargetPosToLane(nextNote.TargetPos), 0)
-				commands = append(commands, moveCmd)
+		// リリース
+		releaseAll(RuleRelease, releaseMs)
+
+		// 移動 (離れ終わってから、待たせる位置へ動く)
+		moveMs := releaseMs + profile.Solenoid.OffLatencyMs
+		idle := Idle{
+			Hand:    hand,
+			Lane:    position,
+			Next:    home,
+			Home:    home,
+			Break:   nextStep == nil || nextStep[0].IsGap(),
+			Profile: profile,
+		}
+		untilMs := math.MaxInt
+		for _, later := range steps[i+1:] {
+			if !later[0].IsGap() {
+				idle.Next = carriageLane(later[0].Note)
+				idle.HasNext = true
+				untilMs = noteTimeMs(later[0].Note)
+				idle.FreeMs = untilMs - moveMs
+				idle.Break = idle.Break || idle.FreeMs >= breakMs
+				break
 			}
 		}
+		idle.Other = lanesDuring(other, otherHome, moveMs, untilMs)
+		at = parking.Park(idle)
+		if at != position {
+			emit(RulePark, CommandArm.NewCommandMove(moveMs, hand, at), parkingDetail(idle, at))
+		} else {
+			explain.skipped(RulePark, "stay at %s", at)
+		}
 	}
 
 	return commands
 }
 
+// parkingDetail は待つ位置を選んだ状況を表します
+func parkingDetail(idle Idle, lane CommandArm.Lane) string {
+	switch {
+	case !idle.HasNext:
+		return "end of the chart"
+	case lane == idle.Next:
+		return fmt.Sprintf("next note at %s in %d ms", idle.Next, idle.FreeMs)
+	case idle.Break:
+		return fmt.Sprintf("break of %d ms", idle.FreeMs)
+	default:
+		return fmt.Sprintf("idle for %d ms", idle.FreeMs)
+	}
+}
+
+// noteTime はノーツをコマンドの時刻 (ミリ秒) に変換します
+type noteTime func(note ScoreSingleHand.Note) int
+
+// fixedTempo は一定のテンポ bpm で、0 小節目を offset ミリ秒に置いた時刻を返します
+func fixedTempo(bpm float64, offset int) noteTime {
+	// 1小節あたりの時間（ミリ秒）
+	measureTimeMs := 60000.0 * 4.0 / bpm
+	return func(note ScoreSingleHand.Note) int {
+		return int(measureTimeMs*float64(note.Measure)+measureTimeMs*float64(note.Beat)/float64(note.BeatSet)) + offset
+	}
+}
+
+// sourceOf はノーツをコマンドの Source に変換します
+func sourceOf(note ScoreSingleHand.Note) CommandArm.Source {
+	return CommandArm.Source{
+		Channel: note.Channel,
+		Measure: note.Measure,
+		Beat:    note.Beat,
+		BeatSet: note.BeatSet,
+		Note:    note.Note,
+		ID:      note.ID(),
+	}
+}
+
+// groupSteps は同時刻に始まる単独ノーツの動作を1つのまとまりにします
+// ロング・スライドと区切りはそれぞれ単独のまとまりになります
+func groupSteps(actions []ScoreSingleHand.Action) [][]ScoreSingleHand.Action {
+	steps := [][]ScoreSingleHand.Action{}
+	for i, action := range actions {
+		if i > 0 && action.Hold == nil && !action.IsGap() {
+			last := steps[len(steps)-1]
+			if last[0].Hold == nil && !last[0].IsGap() && last[0].Note.Position().Compare(action.Note.Position()) == 0 {
+				steps[len(steps)-1] = append(last, action)
+				continue
+			}
+		}
+		steps = append(steps, []ScoreSingleHand.Action{action})
+	}
+	return steps
+}
+
+func isFlick(note ScoreDeleste.NoteType) bool {
+	return note == ScoreDeleste.LeftFlick || note == ScoreDeleste.RightFlick
+}
+
 func convertTargetPosToLane(targetPos int) CommandArm.Lane {
 	switch {
 	case targetPos <= 0:
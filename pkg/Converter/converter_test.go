@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
 	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
@@ -13,7 +14,7 @@ import (
 func TestGenerateHandCommands(t *testing.T) {
 	t.Run("empty notes list", func(t *testing.T) {
 		notes := []ScoreSingleHand.Note{}
-		commands := generateHandCommands(notes, CommandArm.Left, 120.0, 0)
+		commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0))
 		assert.Empty(t, commands)
 	})
 
@@ -39,21 +40,24 @@ func TestGenerateHandCommands(t *testing.T) {
 				TargetPos: 5,
 			},
 		}
+		// ArmProfile.Default() では従来の固定値から次の点が変わっている
+		// - 最初の移動は一律 300ms 前ではなく、待機位置 LL から 2C への移動時間 (267ms) だけ前に始める
+		// - 同じ時刻では離してから動く (従来は移動が先で、押したまま動き出していた)
+		// - 次のノーツが同じレーンなら、今いるレーンへの移動は出さない
 		expected := []string{
-			"M -300 L 2C 0",
+			"M -267 L 2C 0",
 			"S 0 L ON",
-			"M 10 L 2C 0",
 			"S 10 L OF",
 			"S 500 L ON",
-			"M 510 L 5C 0",
 			"S 510 L OF",
+			"M 510 L 5C",
 			"S 1000 L ON",
-			"M 1010 L LL 0",
 			"S 1010 L OF",
+			"M 1010 L LL",
 		}
 
-		commands := generateHandCommands(notes, CommandArm.Left, 120.0, 0)
-		assert.Len(t, commands, 10)
+		commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 9)
 		for i, command := range commands {
 			assert.Equal(t, expected[i], command.Message())
 		}
@@ -81,23 +85,253 @@ func TestGenerateHandCommands(t *testing.T) {
 				TargetPos: 3,
 			},
 		}
+		// 最初の移動は待機位置 RR から 2C への移動時間 (467ms) だけ前に始める (従来は一律 300ms)
+		// ロングの終点では離した時刻にそのまま待機位置へ向かう (従来はタップと同じく 10ms 後)
 		expected := []string{
-			"M -300 R 2C 0",
+			"M -467 R 2C 0",
 			"S 0 R ON",
 			"S 10 R OF",
-			"M 10 R 3C 0",
+			"M 10 R 3C",
 			"S 500 R ON",
 			"S 1000 R OF",
-			"M 1010 R RR 0",
+			"M 1000 R RR",
 		}
 
-		commands := generateHandCommands(notes, CommandArm.Right, 120.0, 0)
+		commands := generateHandCommands(notes, CommandArm.Right, ArmProfile.Default(), fixedTempo(120.0, 0))
 		assert.Len(t, commands, 7)
 		for i, command := range commands {
 			assert.Equal(t, expected[i], command.Message())
 		}
 	})
 
+	t.Run("long note ending with flick", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{
+				Channel:   1,
+				Measure:   0,
+				Beat:      0,
+				BeatSet:   4,
+				Note:      ScoreDeleste.LongStart,
+				TargetPos: 3,
+			}, {
+				Channel:   1,
+				Measure:   0,
+				Beat:      1,
+				BeatSet:   4,
+				Note:      ScoreDeleste.RightFlick,
+				TargetPos: 3,
+			}, {
+				Channel:   3,
+				Measure:   0,
+				Beat:      2,
+				BeatSet:   4,
+				Note:      ScoreDeleste.Tap,
+				TargetPos: 5,
+			},
+		}
+		expected := []string{
+			"M -367 R 3C 0",
+			"S 0 R ON",
+			"M 500 R 3R",
+			"S 510 R OF",
+			"M 510 R 5C",
+			"S 1000 R ON",
+			"S 1010 R OF",
+			"M 1010 R RR",
+		}
+
+		commands := generateHandCommands(notes, CommandArm.Right, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 8)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("chord on a dual tip arm", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{
+				Channel:   1,
+				Measure:   0,
+				Beat:      0,
+				BeatSet:   4,
+				Note:      ScoreDeleste.Tap,
+				TargetPos: 3,
+				Actuator:  0,
+			}, {
+				Channel:   3,
+				Measure:   0,
+				Beat:      0,
+				BeatSet:   4,
+				Note:      ScoreDeleste.Tap,
+				TargetPos: 4,
+				Actuator:  1,
+			}, {
+				Channel:   1,
+				Measure:   0,
+				Beat:      1,
+				BeatSet:   4,
+				Note:      ScoreDeleste.Tap,
+				TargetPos: 5,
+				Actuator:  1,
+			},
+		}
+		expected := []string{
+			"M -367 R 3C 0",
+			"S 0 R ON",
+			"S 0 R ON 1",
+			"S 10 R OF",
+			"S 10 R OF 1",
+			"M 10 R 4C",
+			"S 500 R ON 1",
+			"S 510 R OF 1",
+			"M 510 R RR",
+		}
+
+		profile := ArmProfile.Default()
+		profile.Arms.Right = CommandArm.DualTip
+		commands := generateHandCommands(notes, CommandArm.Right, profile, fixedTempo(120.0, 0))
+		assert.Len(t, commands, 9)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("slide through relay points", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{Channel: 1, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 1},
+			{Channel: 1, Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 2},
+			{Channel: 1, Measure: 0, Beat: 2, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 4},
+		}
+		expected := []string{
+			"M -164 L 1C 0",
+			"S 0 L ON",
+			"M 0 L 2C 500",
+			"M 500 L 4C 1000",
+			"S 1000 L OF",
+			"M 1000 L LL",
+		}
+
+		commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 6)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("slide ending with flick", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{Channel: 1, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 4},
+			{Channel: 1, Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 3},
+			{Channel: 1, Measure: 0, Beat: 2, BeatSet: 4, Note: ScoreDeleste.LeftFlick, TargetPos: 2},
+			{Channel: 3, Measure: 0, Beat: 3, BeatSet: 4, Note: ScoreDeleste.Tap, TargetPos: 2},
+		}
+		expected := []string{
+			"M -267 R 4C 0",
+			"S 0 R ON",
+			"M 0 R 3C 500",
+			"M 500 R 2C 1000",
+			"M 1000 R 2L",
+			"S 1010 R OF",
+			"M 1010 R 2C",
+			"S 1500 R ON",
+			"S 1510 R OF",
+			"M 1510 R RR",
+		}
+
+		commands := generateHandCommands(notes, CommandArm.Right, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 10)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("same direction flick chain", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{Channel: 1, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LeftFlick, TargetPos: 4},
+			{Channel: 1, Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.LeftFlick, TargetPos: 3},
+		}
+		expected := []string{
+			"M -267 R 4C 0",
+			"S 0 R ON",
+			"M 0 R 4L",
+			"M 500 R 3L",
+			"S 510 R OF",
+			"M 510 R RR",
+		}
+
+		commands := generateHandCommands(notes, CommandArm.Right, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 6)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("alternating flick chain", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{Channel: 1, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LeftFlick, TargetPos: 3},
+			{Channel: 1, Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.RightFlick, TargetPos: 3},
+		}
+		expected := []string{
+			"M -367 R 3C 0",
+			"S 0 R ON",
+			"M 0 R 3L",
+			"M 500 R 3R",
+			"S 510 R OF",
+			"M 510 R RR",
+		}
+
+		commands := generateHandCommands(notes, CommandArm.Right, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 6)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("flick chain breaks when moving backwards", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{Channel: 0, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LeftFlick, TargetPos: 2},
+			{Channel: 0, Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.LeftFlick, TargetPos: 4},
+		}
+		expected := []string{
+			"M -267 L 2C 0",
+			"S 0 L ON",
+			"M 0 L 2L",
+			"S 10 L OF",
+			"M 10 L 4C",
+			"S 500 L ON",
+			"M 500 L 4L",
+			"S 510 L OF",
+			"M 510 L LL",
+		}
+
+		commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0))
+		assert.Len(t, commands, 9)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
+	t.Run("configured flick is clamped to the rail", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			{Channel: 1, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.RightFlick, TargetPos: 5},
+		}
+		// 払い終えた RR が待機位置なので、離した後の移動は無い
+		expected := []string{
+			"M -164 R 5C 0",
+			"S 0 R ON",
+			"M 0 R RR 20",
+			"S 25 R OF",
+		}
+
+		profile := ArmProfile.Default()
+		profile.Flick = ArmProfile.Flick{Distance: 2, MinTravelMs: 20, ReleaseDelayMs: 5}
+		commands := generateHandCommands(notes, CommandArm.Right, profile, fixedTempo(120.0, 0))
+		assert.Len(t, commands, 4)
+		for i, command := range commands {
+			assert.Equal(t, expected[i], command.Message())
+		}
+	})
+
 	// t.Run("consecutive flick notes", func(t *testing.T) {
 	// 	notes := []ScoreSingleHand.Note{
 	// 		{
@@ -115,7 +349,7 @@ func TestGenerateHandCommands(t *testing.T) {
 	// 			TargetPos: 3,
 	// 		},
 	// 	}
-	// 	commands := generateHandCommands(notes, CommandArm.Right, 120.0, 0)
+	// 	commands := generateHandCommands(notes, CommandArm.Right, ArmProfile.Default(), fixedTempo(120.0, 0))
 	// 	assert.NotEmpty(t, commands)
 	// })
 
@@ -136,7 +370,7 @@ func TestGenerateHandCommands(t *testing.T) {
 	// 			TargetPos: 2,
 	// 		},
 	// 	}
-	// 	commands := generateHandCommands(notes, CommandArm.Left, 120.0, 100)
+	// 	commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 100))
 	// 	assert.NotEmpty(t, commands)
 	// })
 
@@ -151,7 +385,7 @@ func TestGenerateHandCommands(t *testing.T) {
 	// 		},
 	// 	}
 	// 	offset := 1000
-	// 	commands := generateHandCommands(notes, CommandArm.Left, 120.0, offset)
+	// 	commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, offset))
 	// 	assert.Equal(t, offset-300, commands[0].GetTime())
 	// })
 
@@ -165,7 +399,7 @@ func TestGenerateHandCommands(t *testing.T) {
 	// 			TargetPos: 0,
 	// 		},
 	// 	}
-	// 	commands := generateHandCommands(notes, CommandArm.Left, 120.0, 0)
+	// 	commands := generateHandCommands(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0))
 	// 	lastCommand := commands[len(commands)-2]
 	// 	assert.Equal(t, CommandArm.LeftEdge, lastCommand.GetLane())
 	// })
@@ -0,0 +1,158 @@
+package Converter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+// Kind は変換できないノーツの並びの種類です
+type Kind int
+
+const (
+	ConsecutiveLongStart Kind = iota + 1 // ロングの終点が LongStart
+	HoldLaneChange                       // ロングの終点が始点と違うレーン
+	HoldBrokenByGap                      // ロング・スライドの途中に None がある
+	NoteInsideHold                       // ロング・スライドの途中に同じ手の別のノーツがある
+	UnterminatedHold                     // ロング・スライドに終点が無い
+	ChordOnOneArm                        // 1本の腕で押せない同時押しが同じ手に振り分けられた (ScoreSingleHand.ChordConflict)
+	AssignedDuringHold                   // ロング・スライドの途中の手に振り分けられた (ScoreSingleHand.HoldConflict)
+	Unassigned                           // どちらの手にも振り分けられなかった (ScoreSingleHand.UnplacedError)
+)
+
+func (k Kind) String() string {
+	switch k {
+	case ConsecutiveLongStart:
+		return "long note followed by another LongStart"
+	case HoldLaneChange:
+		return "long note ends on a different lane"
+	case HoldBrokenByGap:
+		return "hold broken by a gap"
+	case NoteInsideHold:
+		return "note inside a hold"
+	case UnterminatedHold:
+		return "hold without an end"
+	case ChordOnOneArm:
+		return "chord on a single arm"
+	case AssignedDuringHold:
+		return "note assigned to an arm in a hold"
+	case Unassigned:
+		return "note assigned to neither arm"
+	default:
+		return "unknown"
+	}
+}
+
+// Issue は変換の規則で扱えず、コマンドが不完全になるノーツの並びです
+type Issue struct {
+	Kind   Kind
+	Hand   CommandArm.Hand
+	Source CommandArm.Source // 問題のあるノーツ
+	Detail string            // 関係するもう一方のノーツなど
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s: %s (%s)", i.Hand, i.Source, i.Kind, i.Detail)
+}
+
+// ConversionError は変換できなかったノーツの並びの一覧です
+type ConversionError struct {
+	Issues []Issue
+}
+
+func (e *ConversionError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return fmt.Sprintf("%d unsupported note sequences: %s", len(e.Issues), strings.Join(issues, "; "))
+}
+
+// assignmentIssues は手の振り分けのエラーを Issue にします
+// 振り分けのエラーとして知らない種類が含まれる場合は false を返します
+func assignmentIssues(err error) ([]Issue, bool) {
+	issues := []Issue{}
+	var chordErr *ScoreSingleHand.ChordConflictError
+	if errors.As(err, &chordErr) {
+		for _, c := range chordErr.Conflicts {
+			lanes := make([]string, len(c.Notes))
+			for i, n := range c.Notes {
+				lanes[i] = fmt.Sprint(n.TargetPos)
+			}
+			issues = append(issues, Issue{Kind: ChordOnOneArm, Hand: c.Hand, Source: sourceOf(c.Notes[0]), Detail: "lanes " + strings.Join(lanes, ",")})
+		}
+	}
+	var holdErr *ScoreSingleHand.HoldConflictError
+	if errors.As(err, &holdErr) {
+		for _, c := range holdErr.Conflicts {
+			issues = append(issues, Issue{Kind: AssignedDuringHold, Hand: c.Hand, Source: sourceOf(c.Note), Detail: fmt.Sprintf("during the hold from %s", sourceOf(c.Hold))})
+		}
+	}
+	var unplacedErr *ScoreSingleHand.UnplacedError
+	if errors.As(err, &unplacedErr) {
+		for _, n := range unplacedErr.Notes {
+			issues = append(issues, Issue{Kind: Unassigned, Source: sourceOf(n), Detail: "no arm is free"})
+		}
+	}
+	return issues, !hasUnknown(err)
+}
+
+// hasUnknown は err (errors.Join でまとめたものを含む) に振り分けのエラー以外が含まれるかを返します
+func hasUnknown(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if hasUnknown(e) {
+				return true
+			}
+		}
+		return false
+	}
+	switch err.(type) {
+	case *ScoreSingleHand.ChordConflictError, *ScoreSingleHand.HoldConflictError, *ScoreSingleHand.UnplacedError:
+		return false
+	default:
+		return true
+	}
+}
+
+// checkHand は片手分のノーツから、generateHandCommands の規則で扱えない並びを探します
+func checkHand(notes []ScoreSingleHand.Note, hand CommandArm.Hand) []Issue {
+	issues := []Issue{}
+	report := func(kind Kind, note ScoreSingleHand.Note, detail string) {
+		issues = append(issues, Issue{Kind: kind, Hand: hand, Source: sourceOf(note), Detail: detail})
+	}
+
+	actions := ScoreSingleHand.BuildActions(notes)
+	for _, action := range actions {
+		hold := action.Hold
+		if hold == nil {
+			continue
+		}
+		start, end := hold.Start, hold.End
+		switch {
+		case end == start:
+			report(UnterminatedHold, start, "no end note on the same channel")
+			continue
+		case end.Note == ScoreDeleste.None:
+			report(HoldBrokenByGap, start, fmt.Sprintf("ends at gap %s", sourceOf(end)))
+		case !hold.IsSlide() && end.Note == ScoreDeleste.LongStart:
+			report(ConsecutiveLongStart, start, fmt.Sprintf("ends at %s", sourceOf(end)))
+		case !hold.IsSlide() && end.TargetPos != start.TargetPos:
+			report(HoldLaneChange, start, fmt.Sprintf("starts at lane %d, ends at lane %d", start.TargetPos, end.TargetPos))
+		}
+
+		// 終点までに始まる同じ手の動作
+		for _, other := range hold.Inside {
+			if other.IsGap() {
+				report(HoldBrokenByGap, start, fmt.Sprintf("gap %s before the end %s", sourceOf(other.Note), sourceOf(end)))
+			} else {
+				report(NoteInsideHold, other.Note, fmt.Sprintf("inside the hold from %s to %s", sourceOf(start), sourceOf(end)))
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,167 @@
+package Converter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+func note(channel, beat int, noteType ScoreDeleste.NoteType, pos int) ScoreSingleHand.Note {
+	return ScoreSingleHand.Note{Channel: channel, Measure: 0, Beat: beat, BeatSet: 4, Note: noteType, TargetPos: pos}
+}
+
+func TestCheckHand(t *testing.T) {
+	t.Run("supported holds", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{
+			note(0, 0, ScoreDeleste.LongStart, 2),
+			note(0, 1, ScoreDeleste.Tap, 2),
+			note(1, 2, ScoreDeleste.Slide, 2),
+			note(1, 3, ScoreDeleste.Slide, 4),
+		}
+		assert.Empty(t, checkHand(notes, CommandArm.Left))
+	})
+
+	cases := []struct {
+		name   string
+		notes  []ScoreSingleHand.Note
+		kind   Kind
+		source CommandArm.Source
+	}{
+		{
+			name:   "LongStart followed by LongStart",
+			notes:  []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(0, 1, ScoreDeleste.LongStart, 2)},
+			kind:   ConsecutiveLongStart,
+			source: CommandArm.Source{Channel: 0, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LongStart, ID: "0+0/1@2#0"},
+		},
+		{
+			name:   "hold ending on a different lane",
+			notes:  []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(0, 1, ScoreDeleste.Tap, 3)},
+			kind:   HoldLaneChange,
+			source: CommandArm.Source{Channel: 0, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LongStart, ID: "0+0/1@2#0"},
+		},
+		{
+			name:   "hold ended by a None",
+			notes:  []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(0, 1, ScoreDeleste.None, 2), note(0, 2, ScoreDeleste.Tap, 2)},
+			kind:   HoldBrokenByGap,
+			source: CommandArm.Source{Channel: 0, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LongStart, ID: "0+0/1@2#0"},
+		},
+		{
+			name:   "None on another channel inside a hold",
+			notes:  []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(1, 1, ScoreDeleste.None, 0), note(0, 2, ScoreDeleste.Tap, 2)},
+			kind:   HoldBrokenByGap,
+			source: CommandArm.Source{Channel: 0, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LongStart, ID: "0+0/1@2#0"},
+		},
+		{
+			name:   "tap inside a hold",
+			notes:  []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(1, 1, ScoreDeleste.Tap, 4), note(0, 2, ScoreDeleste.Tap, 2)},
+			kind:   NoteInsideHold,
+			source: CommandArm.Source{Channel: 1, Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.Tap, ID: "0+1/4@4#1"},
+		},
+		{
+			name:   "hold without an end",
+			notes:  []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2)},
+			kind:   UnterminatedHold,
+			source: CommandArm.Source{Channel: 0, Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.LongStart, ID: "0+0/1@2#0"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			issues := checkHand(c.notes, CommandArm.Right)
+			assert.Len(t, issues, 1)
+			assert.Equal(t, c.kind, issues[0].Kind)
+			assert.Equal(t, CommandArm.Right, issues[0].Hand)
+			assert.Equal(t, c.source, issues[0].Source)
+		})
+	}
+
+	t.Run("message", func(t *testing.T) {
+		issues := checkHand([]ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(0, 1, ScoreDeleste.Tap, 3)}, CommandArm.Left)
+		assert.Equal(t, "L ch0 0:0/4 LongStart [0+0/1@2#0]: long note ends on a different lane (starts at lane 2, ends at lane 3)", issues[0].String())
+	})
+}
+
+func TestConversionErrors(t *testing.T) {
+	t.Run("ConvertToCommands returns the commands and the error", func(t *testing.T) {
+		left, _, err := ConvertToCommandsWithProfile([]ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2)}, nil, ArmProfile.Default(), 120.0, 0)
+		var conversionErr *ConversionError
+		assert.True(t, errors.As(err, &conversionErr))
+		assert.Len(t, conversionErr.Issues, 1)
+		assert.NotEmpty(t, left)
+	})
+
+	t.Run("notes inside a hold are left out", func(t *testing.T) {
+		notes := []ScoreSingleHand.Note{note(0, 0, ScoreDeleste.LongStart, 2), note(1, 1, ScoreDeleste.Tap, 4), note(0, 2, ScoreDeleste.Tap, 2)}
+		left, _, err := ConvertToCommandsWithProfile(notes, nil, ArmProfile.Default(), 120.0, 0)
+		var conversionErr *ConversionError
+		assert.True(t, errors.As(err, &conversionErr))
+		assert.Equal(t, NoteInsideHold, conversionErr.Issues[0].Kind)
+		// ロングを離す前後のコマンドが時刻順のまま並ぶ
+		for i, c := range left {
+			assert.NotEqual(t, 1, CommandArm.SourceOf(c).Channel)
+			if i > 0 {
+				assert.LessOrEqual(t, left[i-1].TimeMs(), c.TimeMs())
+			}
+		}
+	})
+
+	// 0小節目の 1/4 から始まるロングが、3/4 の LongStart で終わる
+	score := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{BPM: 120},
+		Notes: []ScoreDeleste.Note{
+			{Channel: 0, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.None, ScoreDeleste.LongStart, ScoreDeleste.None, ScoreDeleste.LongStart}, StartPos: []int{2, 2}, TargetPos: []int{2, 2}},
+		},
+	}
+
+	t.Run("strict mode fails", func(t *testing.T) {
+		result, err := ConvertScore(score, DefaultOptions())
+		var conversionErr *ConversionError
+		assert.True(t, errors.As(err, &conversionErr))
+		assert.Equal(t, ConsecutiveLongStart, conversionErr.Issues[0].Kind)
+		assert.Equal(t, 1, conversionErr.Issues[0].Source.Beat)
+		assert.Empty(t, result.Left)
+		assert.Empty(t, result.Right)
+	})
+
+	t.Run("lenient mode collects warnings", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Lenient = true
+		result, err := ConvertScore(score, opts)
+		assert.NoError(t, err)
+		assert.Len(t, result.Warnings, 1)
+		assert.Equal(t, ConsecutiveLongStart, result.Warnings[0].Kind)
+		assert.NotEmpty(t, append(result.Left, result.Right...))
+	})
+
+	// 右手がふさがっている時刻に、左手に振り分けたレーン 1,2 の同時押し
+	chord := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{BPM: 120},
+		Notes: []ScoreDeleste.Note{
+			{Channel: 0, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{1}, TargetPos: []int{1}},
+			{Channel: 1, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{5}, TargetPos: []int{5}},
+			{Channel: 2, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{2}, TargetPos: []int{2}},
+		},
+	}
+
+	t.Run("assignment conflicts fail in strict mode", func(t *testing.T) {
+		_, err := ConvertScore(chord, DefaultOptions())
+		var chordErr *ScoreSingleHand.ChordConflictError
+		assert.ErrorAs(t, err, &chordErr)
+	})
+
+	t.Run("assignment conflicts become warnings in lenient mode", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Lenient = true
+		result, err := ConvertScore(chord, opts)
+		assert.NoError(t, err)
+		assert.Len(t, result.Warnings, 1)
+		assert.Equal(t, "L ch0 0:0/1 Tap [0+0/1@1#0]: chord on a single arm (lanes 1,2)", result.Warnings[0].String())
+		assert.NotEmpty(t, result.Left)
+		assert.NotEmpty(t, result.Right)
+	})
+}
@@ -0,0 +1,144 @@
+package Converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+// Rule は generateHandCommands がコマンドを出すかどうかを決める規則です
+type Rule int
+
+const (
+	RulePreMove        Rule = iota + 1 // 待っていた位置からノーツの位置へ動く
+	RulePress                          // 押す
+	RuleSlideRelay                     // スライドの中継点へ動く
+	RuleHoldEndByTap                   // ロング・スライドの終点で離す
+	RuleHoldEndByFlick                 // ロング・スライドの終点でフリックする
+	RuleFlick                          // フリックする
+	RuleKeepPressed                    // 次のフリックへ押したままつなぐ
+	RuleRelease                        // 離す
+	RulePark                           // 次のノーツまで待つ位置へ動く
+	RuleUnsupported                    // 扱える規則が無い
+)
+
+func (r Rule) String() string {
+	switch r {
+	case RulePreMove:
+		return "pre-move to the note"
+	case RulePress:
+		return "press"
+	case RuleSlideRelay:
+		return "slide relay move"
+	case RuleHoldEndByTap:
+		return "hold end by tap"
+	case RuleHoldEndByFlick:
+		return "hold end by flick"
+	case RuleFlick:
+		return "flick"
+	case RuleKeepPressed:
+		return "release suppressed because next is flick"
+	case RuleRelease:
+		return "release"
+	case RulePark:
+		return "park"
+	case RuleUnsupported:
+		return "no rule for the note"
+	default:
+		return "unknown"
+	}
+}
+
+// Decision は1つの規則を当てはめた結果です
+type Decision struct {
+	Rule    Rule
+	Fired   bool               // 規則が当てはまったか (false は検討して見送った)
+	Command CommandArm.Command // 規則が出したコマンド (出さない場合は nil)
+	Detail  string             // 見送った理由など
+}
+
+func (d Decision) String() string {
+	if !d.Fired {
+		return fmt.Sprintf("- %s: %s", d.Rule, d.Detail)
+	}
+	line := "+ " + d.Rule.String()
+	if d.Command != nil {
+		line += ": " + d.Command.Message()
+	}
+	if d.Detail != "" {
+		line += " (" + d.Detail + ")"
+	}
+	return line
+}
+
+// Explanation は1つの動作 (単独のノーツ、同時押し、ロング・スライド) について当てはめた規則の記録です
+type Explanation struct {
+	Hand      CommandArm.Hand
+	Notes     []CommandArm.Source // 動作の元になったノーツ
+	Decisions []Decision          // 当てはめた順
+}
+
+// String はノーツを1行目に、規則を1行ずつ字下げして並べた注釈付きの一覧を返します
+func (e Explanation) String() string {
+	notes := make([]string, len(e.Notes))
+	for i, n := range e.Notes {
+		notes[i] = n.String()
+	}
+	lines := []string{fmt.Sprintf("%s %s", e.Hand, strings.Join(notes, ", "))}
+	for _, d := range e.Decisions {
+		lines = append(lines, "  "+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// explainer は変換中に当てはめた規則を記録します (nil の場合は何もしません)
+type explainer struct {
+	hand         CommandArm.Hand
+	explanations []Explanation
+}
+
+func newExplainer(enabled bool, hand CommandArm.Hand) *explainer {
+	if !enabled {
+		return nil
+	}
+	return &explainer{hand: hand}
+}
+
+// begin は新しい動作の記録を始めます
+func (e *explainer) begin(step []ScoreSingleHand.Action) {
+	if e == nil {
+		return
+	}
+	notes := make([]CommandArm.Source, len(step))
+	for i, action := range step {
+		notes[i] = sourceOf(action.Note)
+	}
+	e.explanations = append(e.explanations, Explanation{Hand: e.hand, Notes: notes})
+}
+
+func (e *explainer) record(decision Decision) {
+	if e == nil || len(e.explanations) == 0 {
+		return
+	}
+	last := &e.explanations[len(e.explanations)-1]
+	last.Decisions = append(last.Decisions, decision)
+}
+
+// fired は規則が当てはまったことを記録します
+func (e *explainer) fired(rule Rule, command CommandArm.Command, detail string) {
+	e.record(Decision{Rule: rule, Fired: true, Command: command, Detail: detail})
+}
+
+// skipped は規則を検討して見送ったことを記録します
+func (e *explainer) skipped(rule Rule, format string, args ...any) {
+	e.record(Decision{Rule: rule, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (e *explainer) result() []Explanation {
+	if e == nil {
+		return nil
+	}
+	return e.explanations
+}
@@ -0,0 +1,85 @@
+package Converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+func TestExplain(t *testing.T) {
+	notes := []ScoreSingleHand.Note{
+		note(0, 0, ScoreDeleste.LongStart, 2),
+		note(0, 1, ScoreDeleste.Tap, 2),
+		note(1, 2, ScoreDeleste.RightFlick, 3),
+		note(1, 3, ScoreDeleste.RightFlick, 4),
+	}
+	explain := newExplainer(true, CommandArm.Left)
+	commands := generateHand(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0), DefaultParking, DefaultBreakMs, nil, explain)
+	explanations := explain.result()
+
+	t.Run("annotated listing", func(t *testing.T) {
+		listing := []string{}
+		for _, e := range explanations {
+			listing = append(listing, e.String())
+		}
+		assert.Equal(t, strings.Join([]string{
+			"L ch0 0:0/4 LongStart [0+0/1@2#0]",
+			"  + pre-move to the note: M -267 L 2C 0 (from LL, 267 ms ahead)",
+			"  + press: S 0 L ON",
+			"  + hold end by tap: S 500 L OF",
+			"  - release suppressed because next is flick: already released",
+			"  - release: nothing pressed",
+			"  + park: M 500 L 3C (next note at 3C in 500 ms)",
+			"L ch1 0:2/4 RightFlick [0+1/2@3#1]",
+			"  - pre-move to the note: already waiting at 3C",
+			"  + press: S 1000 L ON",
+			"  + flick: M 1000 L 3R",
+			"  + release suppressed because next is flick (next RightFlick at 4C)",
+			"L ch1 0:3/4 RightFlick [0+3/4@4#1]",
+			"  - pre-move to the note: held through from the previous flick",
+			"  - press: actuator 0 already pressed",
+			"  + flick: M 1500 L 4R",
+			"  - release suppressed because next is flick: last note",
+			"  + release: S 1510 L OF",
+			"  + park: M 1510 L LL (end of the chart)",
+		}, "\n"), strings.Join(listing, "\n"))
+	})
+
+	t.Run("every command comes from one fired rule", func(t *testing.T) {
+		fired := []CommandArm.Command{}
+		for _, e := range explanations {
+			for _, d := range e.Decisions {
+				if d.Command != nil {
+					assert.True(t, d.Fired)
+					fired = append(fired, d.Command)
+				}
+			}
+		}
+		assert.Equal(t, commands, fired)
+	})
+
+	t.Run("explanations are off by default", func(t *testing.T) {
+		score := &ScoreDeleste.Score{
+			Header: ScoreDeleste.Header{BPM: 120},
+			Notes: []ScoreDeleste.Note{
+				{Channel: 0, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{2}, TargetPos: []int{2}},
+			},
+		}
+		result, err := ConvertScore(score, DefaultOptions())
+		assert.NoError(t, err)
+		assert.Nil(t, result.Explanations)
+
+		opts := DefaultOptions()
+		opts.Explain = true
+		result, err = ConvertScore(score, opts)
+		assert.NoError(t, err)
+		assert.Len(t, result.Explanations, 1)
+		assert.Equal(t, CommandArm.Left, result.Explanations[0].Hand)
+	})
+}
@@ -0,0 +1,48 @@
+package Converter
+
+import (
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// flickDirection はフリックの向き (左 -1、右 1、フリックでなければ 0) を返します
+func flickDirection(note ScoreDeleste.NoteType) int {
+	switch note {
+	case ScoreDeleste.LeftFlick:
+		return -1
+	case ScoreDeleste.RightFlick:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// flickTarget は lane から払ったときの到達位置を返します
+func flickTarget(f ArmProfile.Flick, lane CommandArm.Lane, direction int) CommandArm.Lane {
+	return lane.Shift(direction * f.Distance)
+}
+
+// flickMove は timeMs に lane から払う移動コマンドを作ります
+func flickMove(f ArmProfile.Flick, timeMs int, hand CommandArm.Hand, lane CommandArm.Lane, direction int) CommandArm.Command {
+	target := flickTarget(f, lane, direction)
+	if f.MinTravelMs > 0 {
+		return CommandArm.NewCommandMoveUntil(timeMs, hand, target, timeMs+f.MinTravelMs)
+	}
+	return CommandArm.NewCommandMove(timeMs, hand, target)
+}
+
+// flickReleaseAfterMs は払い始めてから離すまでの時間を返します
+func flickReleaseAfterMs(f ArmProfile.Flick) int {
+	return f.MinTravelMs + f.ReleaseDelayMs
+}
+
+// flickContinues は position で押したまま次のフリックにつなげるかを返します
+// 次のフリックの到達位置がその向きに進んだ先にあるときだけつなぎ、戻る向きに動くならいったん離します
+// 同じ向きの連続は同じ向きに払い続け、向きが交互の連続は折り返して払います
+func flickContinues(f ArmProfile.Flick, position, nextLane CommandArm.Lane, nextDirection int) bool {
+	if nextDirection == 0 {
+		return false
+	}
+	return int(flickTarget(f, nextLane, nextDirection)-position)*nextDirection > 0
+}
@@ -0,0 +1,167 @@
+package Converter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+// Parking は離してから次のノーツまでの間、腕をどこで待たせるかを決めます
+type Parking interface {
+	// Park はキャリッジを待たせる位置を返します
+	Park(idle Idle) CommandArm.Lane
+}
+
+// Idle は腕が次のノーツを待つ間の状況です
+type Idle struct {
+	Hand    CommandArm.Hand
+	Lane    CommandArm.Lane   // 離したときのキャリッジの位置
+	Next    CommandArm.Lane   // 次のノーツを押すキャリッジの位置 (次が無い場合は Home)
+	HasNext bool              // 次のノーツがあるか
+	Home    CommandArm.Lane   // 待機位置
+	FreeMs  int               // 動き始められる時刻から次のノーツまでの時間 (次が無い場合は 0)
+	Break   bool              // 区切り (譜面の None か、次のノーツまで区切りとみなす時間以上空く) か曲の終わりか
+	Other   []CommandArm.Lane // その間にもう一方の腕のキャリッジがいる位置
+	Profile ArmProfile.Profile
+}
+
+// Reachable は lane で待ってから次のノーツに間に合うかを返します
+func (i Idle) Reachable(lane CommandArm.Lane) bool {
+	if !i.HasNext {
+		return true
+	}
+	travelMs := math.Ceil(i.Profile.TravelMs(i.Lane, lane)-1e-9) + math.Ceil(i.Profile.TravelMs(lane, i.Next)-1e-9)
+	return travelMs <= float64(i.FreeMs)
+}
+
+// Clear は lane で待つ間、もう一方の腕と Profile.MinSeparation 以上離れているかを返します
+func (i Idle) Clear(lane CommandArm.Lane) bool {
+	span := CommandArm.Lane(3 * i.Profile.Arms.Left.Span())
+	for _, other := range i.Other {
+		separation := other - (lane + span)
+		if i.Hand == CommandArm.Right {
+			separation = lane - (other + span)
+		}
+		if int(separation) < i.Profile.MinSeparation {
+			return false
+		}
+	}
+	return true
+}
+
+// EdgeParking は区切りと曲の終わりで待機位置 (レールの端) に戻します
+// 待機位置から次のノーツに間に合わない場合は次のノーツの位置で待ちます
+type EdgeParking struct{}
+
+func (EdgeParking) Park(idle Idle) CommandArm.Lane {
+	if (idle.Break || !idle.HasNext) && idle.Reachable(idle.Home) {
+		return idle.Home
+	}
+	return idle.Next
+}
+
+// StayParking は区切りと曲の終わりでその場で待ちます
+// もう一方の腕に近すぎる場合は待機位置に戻し、それも間に合わない場合は次のノーツの位置で待ちます
+type StayParking struct{}
+
+func (StayParking) Park(idle Idle) CommandArm.Lane {
+	switch {
+	case !idle.Break && idle.HasNext:
+		return idle.Next
+	case idle.Clear(idle.Lane) && idle.Reachable(idle.Lane):
+		return idle.Lane
+	case idle.Reachable(idle.Home):
+		return idle.Home
+	default:
+		return idle.Next
+	}
+}
+
+// PrePositionParking は離したらすぐ次のノーツの位置へ動きます
+// その位置がもう一方の腕に近すぎる場合はその場で待ち、それも近すぎれば待機位置に戻します
+// 曲の終わりは待機位置に戻します
+type PrePositionParking struct{}
+
+func (PrePositionParking) Park(idle Idle) CommandArm.Lane {
+	switch {
+	case idle.Clear(idle.Next):
+		return idle.Next
+	case idle.Clear(idle.Lane) && idle.Reachable(idle.Lane):
+		return idle.Lane
+	case idle.Reachable(idle.Home):
+		return idle.Home
+	default:
+		return idle.Next
+	}
+}
+
+// DefaultParking は待たせ方の既定値です
+var DefaultParking Parking = EdgeParking{}
+
+// DefaultBreakMs は区切りとみなす次のノーツまでの待ち時間 (ミリ秒) の既定値です
+// 譜面の None は ScoreSingleHand.Flatten で落ちるので、譜面の途中の区切りはこの時間から決めます
+const DefaultBreakMs = 2000
+
+// 待たせ方の名前と生成関数 (CLI からの選択用)
+var parkings = map[string]func() Parking{
+	"edge":        func() Parking { return EdgeParking{} },
+	"stay":        func() Parking { return StayParking{} },
+	"preposition": func() Parking { return PrePositionParking{} },
+}
+
+// ParkingNames は名前で選べる待たせ方の一覧を返します
+func ParkingNames() []string {
+	names := make([]string, 0, len(parkings))
+	for name := range parkings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewParking は名前から待たせ方を生成します
+func NewParking(name string) (Parking, error) {
+	newParking, ok := parkings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown parking: %s (available: %v)", name, ParkingNames())
+	}
+	return newParking(), nil
+}
+
+// visit は腕がノーツを押す時刻とそのときのキャリッジの位置です
+type visit struct {
+	timeMs int
+	lane   CommandArm.Lane
+}
+
+// visitsOf は片手分のノーツから、キャリッジがいる時刻と位置を時刻順に返します
+func visitsOf(notes []ScoreSingleHand.Note, hand CommandArm.Hand, profile ArmProfile.Profile, noteTimeMs noteTime) []visit {
+	arm := profile.ArmOf(hand)
+	visits := []visit{}
+	for _, note := range notes {
+		if note.Note == ScoreDeleste.None {
+			continue
+		}
+		visits = append(visits, visit{timeMs: noteTimeMs(note), lane: convertTargetPosToLane(note.TargetPos - arm.Offset(note.Actuator))})
+	}
+	return visits
+}
+
+// lanesDuring は fromMs から toMs の間にキャリッジがいる位置を返します (直前にいた位置を含む)
+func lanesDuring(visits []visit, home CommandArm.Lane, fromMs, toMs int) []CommandArm.Lane {
+	lanes := []CommandArm.Lane{home}
+	for _, v := range visits {
+		switch {
+		case v.timeMs <= fromMs:
+			lanes[0] = v.lane
+		case v.timeMs <= toMs:
+			lanes = append(lanes, v.lane)
+		}
+	}
+	return lanes
+}
@@ -0,0 +1,150 @@
+package Converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+// idle は左手が 3C で離し、次のノーツも 3C で押す状況です (3C と LL の間は 367ms)
+func idle(freeMs int, isBreak bool, other ...CommandArm.Lane) Idle {
+	return Idle{
+		Hand:    CommandArm.Left,
+		Lane:    CommandArm.Lane3,
+		Next:    CommandArm.Lane3,
+		HasNext: true,
+		Home:    CommandArm.LeftEdge,
+		FreeMs:  freeMs,
+		Break:   isBreak,
+		Other:   other,
+		Profile: ArmProfile.Default(),
+	}
+}
+
+func TestEdgeParking(t *testing.T) {
+	assert.Equal(t, CommandArm.LeftEdge, EdgeParking{}.Park(idle(1000, true)))
+	// 戻ると間に合わない
+	assert.Equal(t, CommandArm.Lane3, EdgeParking{}.Park(idle(700, true)))
+	// 区切りでなければ戻らない
+	assert.Equal(t, CommandArm.Lane3, EdgeParking{}.Park(idle(1000, false)))
+
+	last := idle(0, true)
+	last.HasNext = false
+	last.Next = last.Home
+	assert.Equal(t, CommandArm.LeftEdge, EdgeParking{}.Park(last))
+}
+
+func TestStayParking(t *testing.T) {
+	assert.Equal(t, CommandArm.Lane3, StayParking{}.Park(idle(1000, true, CommandArm.Lane4)))
+	// 右腕が近づいてくるので待機位置に戻る
+	assert.Equal(t, CommandArm.LeftEdge, StayParking{}.Park(idle(1000, true, CommandArm.Lane4, CommandArm.Lane3Right)))
+	// 戻ると間に合わないので次のノーツの位置で待つ
+	assert.Equal(t, CommandArm.Lane3, StayParking{}.Park(idle(700, true, CommandArm.Lane3Right)))
+}
+
+func TestPrePositionParking(t *testing.T) {
+	i := idle(1000, false)
+	i.Lane = CommandArm.Lane2
+	i.Next = CommandArm.Lane4
+	assert.Equal(t, CommandArm.Lane4, PrePositionParking{}.Park(i))
+
+	// 次のノーツの位置にはまだ右腕がいるので、その場で待つ
+	i.Other = []CommandArm.Lane{CommandArm.Lane4}
+	assert.Equal(t, CommandArm.Lane2, PrePositionParking{}.Park(i))
+}
+
+func TestNewParking(t *testing.T) {
+	parking, err := NewParking("stay")
+	assert.NoError(t, err)
+	assert.Equal(t, StayParking{}, parking)
+
+	_, err = NewParking("unknown")
+	assert.Error(t, err)
+}
+
+func TestGenerateHandParking(t *testing.T) {
+	// 2C を押し、区切りを挟んで 1000ms に 3C を押す
+	notes := []ScoreSingleHand.Note{
+		{Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.Tap, TargetPos: 2},
+		{Measure: 0, Beat: 1, BeatSet: 4, Note: ScoreDeleste.None},
+		{Measure: 0, Beat: 2, BeatSet: 4, Note: ScoreDeleste.Tap, TargetPos: 3},
+	}
+	cases := []struct {
+		name     string
+		parking  Parking
+		expected []string
+	}{
+		{"edge", EdgeParking{}, []string{
+			"M -267 L 2C 0", "S 0 L ON", "S 10 L OF", "M 10 L LL",
+			"M 633 L 3C 1000", "S 1000 L ON", "S 1010 L OF", "M 1010 L LL",
+		}},
+		{"stay", StayParking{}, []string{
+			"M -267 L 2C 0", "S 0 L ON", "S 10 L OF",
+			"M 800 L 3C 1000", "S 1000 L ON", "S 1010 L OF",
+		}},
+		{"preposition", PrePositionParking{}, []string{
+			"M -267 L 2C 0", "S 0 L ON", "S 10 L OF", "M 10 L 3C",
+			"S 1000 L ON", "S 1010 L OF", "M 1010 L LL",
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			commands := generateHand(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0), c.parking, DefaultBreakMs, nil, nil)
+			messages := []string{}
+			for _, command := range commands {
+				messages = append(messages, command.Message())
+			}
+			assert.Equal(t, c.expected, messages)
+		})
+	}
+
+	t.Run("other arm in the way", func(t *testing.T) {
+		// 右腕が 3C を押し終えるまで、左腕は 2C で待つ
+		other := []visit{{timeMs: 500, lane: CommandArm.Lane3}}
+		commands := generateHand(notes, CommandArm.Left, ArmProfile.Default(), fixedTempo(120.0, 0), PrePositionParking{}, DefaultBreakMs, other, nil)
+		assert.Equal(t, "M 800 L 3C 1000", commands[3].Message())
+	})
+}
+
+func TestConvertScoreParking(t *testing.T) {
+	// 2C を押し、16 秒空けて 3C を押す (譜面に None は無い)
+	score := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{BPM: 120},
+		Notes: []ScoreDeleste.Note{
+			{Channel: 0, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{2}, TargetPos: []int{2}},
+			{Channel: 0, Measure: 8, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{3}, TargetPos: []int{3}},
+		},
+	}
+	cases := []struct {
+		name     string
+		parking  Parking
+		breakMs  int
+		expected []string
+	}{
+		{"edge", EdgeParking{}, 0, []string{"M 10 L LL", "M 15633 L 3C 16000"}},
+		{"stay", StayParking{}, 0, []string{"M 15800 L 3C 16000"}},
+		{"preposition", PrePositionParking{}, 0, []string{"M 10 L 3C"}},
+		// 区切りとみなす時間より短ければ、どれも次のノーツの位置へすぐ動く
+		{"edge without a break", EdgeParking{}, 20000, []string{"M 10 L 3C"}},
+		{"stay without a break", StayParking{}, 20000, []string{"M 10 L 3C"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := ConvertScore(score, Options{Profile: ArmProfile.Default(), Parking: c.parking, BreakMs: c.breakMs})
+			assert.NoError(t, err)
+			// 最初のノーツを離してから次のノーツを押すまでの移動
+			messages := []string{}
+			for _, command := range result.Left {
+				if _, ok := command.(*CommandArm.CommandMove); ok && command.TimeMs() >= 10 && command.TimeMs() < 16000 {
+					messages = append(messages, command.Message())
+				}
+			}
+			assert.Equal(t, c.expected, messages)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package Converter
+
+import (
+	"math"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+// Options は ConvertScore の設定です
+type Options struct {
+	Profile  ArmProfile.Profile           // 腕の動作特性
+	Assigner ScoreSingleHand.HandAssigner // 手の振り分け方 (nil の場合は DefaultHandAssigner)
+	Parking  Parking                      // ノーツの間の待たせ方 (nil の場合は DefaultParking)
+	BreakMs  int                          // 次のノーツまでこの時間 (ミリ秒) 以上空けば区切りとする (0 の場合は DefaultBreakMs)
+	OffsetMs int                          // 機器ごとの追加の補正 (ミリ秒、正の値でコマンドを遅らせる)
+	Lenient  bool                         // 扱えないノーツの並びがあっても変換を続け、Result.Warnings に集める
+	Explain  bool                         // 当てはめた規則を Result.Explanations に記録する
+}
+
+// Result は ConvertScore の結果です
+type Result struct {
+	Left         []CommandArm.Command
+	Right        []CommandArm.Command
+	Warnings     []Issue               // Lenient の場合に集めた、変換の規則で扱えなかったノーツの並び
+	Explanations []Explanation         // Explain の場合の、動作ごとに当てはめた規則 (左手、右手の順)
+	Cost         *ScoreSingleHand.Cost // 手の振り分けの評価値 (ScoreSingleHand.Optimal の場合のみ)
+}
+
+// DefaultOptions は既定の腕と手の振り分け方の設定を返します
+func DefaultOptions() Options {
+	return Options{Profile: ArmProfile.Default()}
+}
+
+// ConvertScore は譜面全体を左右のコマンド列に変換します
+// 時刻はヘッダーの BPM と譜面途中のテンポ変化から求め、音源ファイルの再生開始を 0 ミリ秒とします
+// つまりノーツの時刻は ScoreDeleste.Score.AudioTimeMs (譜面先頭からの時間 + Offset - SongOffset) に
+// opts.OffsetMs を足して、最も近いミリ秒に丸めたものです
+// 変換の規則で扱えないノーツの並びがあると、opts.Lenient でなければ ConversionError を返します
+// 手の振り分けで解消できなかった同時押しなども、opts.Lenient であれば Warnings の先頭に入れて変換を続けます
+func ConvertScore(score *ScoreDeleste.Score, opts Options) (Result, error) {
+	assignment, err := ScoreSingleHand.AssignScore(score, opts.Assigner, opts.Profile)
+	assigned := []Issue{}
+	if err != nil {
+		issues, ok := assignmentIssues(err)
+		if !opts.Lenient || !ok {
+			return Result{}, err
+		}
+		assigned = issues
+	}
+	parking := opts.Parking
+	if parking == nil {
+		parking = DefaultParking
+	}
+	breakMs := opts.BreakMs
+	if breakMs == 0 {
+		breakMs = DefaultBreakMs
+	}
+	result := convertHands(assignment.Left, assignment.Right, opts.Profile, audioTime(score, opts.OffsetMs), parking, breakMs, opts.Explain)
+	result.Warnings = append(assigned, result.Warnings...)
+	result.Cost = assignment.Cost
+	if len(result.Warnings) > 0 && !opts.Lenient {
+		return Result{}, &ConversionError{Issues: result.Warnings}
+	}
+	return result, nil
+}
+
+// audioTime はノーツの TimeMs (テンポ変化を含む譜面先頭からの時間) を音源上の時刻にします
+func audioTime(score *ScoreDeleste.Score, offsetMs int) noteTime {
+	shiftMs := float64(score.Header.Offset - score.Header.SongOffset + offsetMs)
+	return func(note ScoreSingleHand.Note) int {
+		return int(math.Round(note.TimeMs + shiftMs))
+	}
+}
@@ -0,0 +1,75 @@
+package Converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+// pressTimes は左右のコマンド列から押す時刻を順に取り出します
+func pressTimes(left, right []CommandArm.Command) []int {
+	times := []int{}
+	for _, command := range append(append([]CommandArm.Command{}, left...), right...) {
+		if s, ok := command.(*CommandArm.CommandSolenoid); ok && s.State() {
+			times = append(times, s.TimeMs())
+		}
+	}
+	return times
+}
+
+func TestConvertScore(t *testing.T) {
+	score := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{BPM: 120, Offset: 1000, SongOffset: 200},
+		Notes: []ScoreDeleste.Note{
+			{Channel: 0, Measure: 0, Note: []ScoreDeleste.NoteType{ScoreDeleste.Tap}, StartPos: []int{3}, TargetPos: []int{3}},
+			{Channel: 0, Measure: 1, Note: []ScoreDeleste.NoteType{ScoreDeleste.None, ScoreDeleste.Tap}, StartPos: []int{3}, TargetPos: []int{3}},
+		},
+		// 1小節目から倍のテンポ (半小節 500ms)
+		BPMChanges: []ScoreDeleste.BPMChange{{Measure: 1, Beat: 0, BeatSet: 1, BPM: 240}},
+	}
+
+	t.Run("header offsets and tempo map", func(t *testing.T) {
+		result, err := ConvertScore(score, DefaultOptions())
+		assert.NoError(t, err)
+		// 譜面先頭からの時間 + Offset - SongOffset
+		assert.Equal(t, []int{800, 3300}, pressTimes(result.Left, result.Right))
+	})
+
+	t.Run("every command traces back to a chart note", func(t *testing.T) {
+		result, err := ConvertScore(score, DefaultOptions())
+		assert.NoError(t, err)
+		ids := map[string]bool{}
+		for _, command := range append(result.Left, result.Right...) {
+			source := CommandArm.SourceOf(command)
+			if assert.NotNil(t, source, command.Message()) {
+				ids[source.ID] = true
+			}
+		}
+		assert.Equal(t, map[string]bool{"0+0/1@3#0": true, "1+1/2@3#0": true}, ids)
+	})
+
+	t.Run("additional device offset", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.OffsetMs = -30
+		result, err := ConvertScore(score, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{770, 3270}, pressTimes(result.Left, result.Right))
+	})
+	t.Run("cost of the optimal assignment", func(t *testing.T) {
+		result, err := ConvertScore(score, DefaultOptions())
+		assert.NoError(t, err)
+		assert.Nil(t, result.Cost)
+
+		opts := DefaultOptions()
+		opts.Assigner = ScoreSingleHand.Optimal{}
+		result, err = ConvertScore(score, opts)
+		assert.NoError(t, err)
+		if assert.NotNil(t, result.Cost) {
+			assert.Equal(t, ScoreSingleHand.Optimal{}.Optimize(ScoreSingleHand.Flatten(score)).Cost, result.Cost)
+		}
+	})
+}
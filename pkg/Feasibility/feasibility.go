@@ -0,0 +1,114 @@
+package Feasibility
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Timeline"
+)
+
+// Kind は違反の種類です
+type Kind int
+
+const (
+	MoveTooSlow   Kind = iota + 1 // 押す時刻までに移動が終わらない
+	PressTooShort                 // 押している時間がソレノイドの最短時間より短い
+)
+
+func (k Kind) String() string {
+	switch k {
+	case MoveTooSlow:
+		return "move too slow"
+	case PressTooShort:
+		return "press too short"
+	default:
+		return "unknown"
+	}
+}
+
+// Violation はコマンド列のうち腕が実行しきれない箇所です
+type Violation struct {
+	Kind   Kind
+	TimeMs int                // 押す時刻 (ミリ秒)
+	Hand   CommandArm.Hand    // どちらの手か
+	Source *CommandArm.Source // 元になったノーツ (不明な場合は nil)
+	Detail string             // 不足している時間などの説明
+}
+
+func (v Violation) String() string {
+	source := "unknown note"
+	if v.Source != nil {
+		source = v.Source.String()
+	}
+	return fmt.Sprintf("%d ms %s %s: %s (%s)", v.TimeMs, v.Hand, source, v.Kind, v.Detail)
+}
+
+// Check はコマンド列を腕の動作特性に沿って再生し、実行しきれない箇所を時刻順に返します
+// 腕の位置と押している時間帯は Timeline で求めます
+// 両手のコマンドが混ざっていても構いません
+func Check(commands []CommandArm.Command, profile ArmProfile.Profile) []Violation {
+	timeline := Timeline.New(commandsOf(commands, CommandArm.Left), commandsOf(commands, CommandArm.Right), profile)
+	violations := []Violation{}
+	for _, hand := range []CommandArm.Hand{CommandArm.Left, CommandArm.Right} {
+		violations = append(violations, checkHand(timeline, hand, profile)...)
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].TimeMs != violations[j].TimeMs {
+			return violations[i].TimeMs < violations[j].TimeMs
+		}
+		return violations[i].Hand < violations[j].Hand
+	})
+	return violations
+}
+
+// commandsOf は hand のコマンドを時刻順に取り出します
+func commandsOf(commands []CommandArm.Command, hand CommandArm.Hand) []CommandArm.Command {
+	result := []CommandArm.Command{}
+	for _, command := range commands {
+		switch c := command.(type) {
+		case *CommandArm.CommandSolenoid:
+			if c.Hand() == hand {
+				result = append(result, c)
+			}
+		case *CommandArm.CommandMove:
+			if c.Hand() == hand {
+				result = append(result, c)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TimeMs() < result[j].TimeMs()
+	})
+	return result
+}
+
+func checkHand(timeline *Timeline.Timeline, hand CommandArm.Hand, profile ArmProfile.Profile) []Violation {
+	violations := []Violation{}
+	for _, p := range timeline.Presses(hand, math.Inf(-1), math.Inf(1)) {
+		if lateMs := p.ReadyMs - p.StartMs; lateMs > 0 {
+			violations = append(violations, Violation{
+				Kind:   MoveTooSlow,
+				TimeMs: int(p.StartMs),
+				Hand:   hand,
+				Source: p.Source,
+				Detail: fmt.Sprintf("arrives at %s %.1f ms late", p.Lane, lateMs),
+			})
+		}
+		if math.IsInf(p.EndMs, 1) {
+			continue
+		}
+		if heldMs := int(p.EndMs - p.StartMs); heldMs < profile.Solenoid.MinPressMs {
+			violations = append(violations, Violation{
+				Kind:   PressTooShort,
+				TimeMs: int(p.StartMs),
+				Hand:   hand,
+				Source: p.Source,
+				Detail: fmt.Sprintf("held %d ms, needs %d ms", heldMs, profile.Solenoid.MinPressMs),
+			})
+		}
+	}
+	return violations
+}
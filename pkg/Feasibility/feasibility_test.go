@@ -0,0 +1,58 @@
+package Feasibility
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Converter"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSingleHand"
+)
+
+func tap(beat, beatSet, pos int) ScoreSingleHand.Note {
+	return ScoreSingleHand.Note{Channel: 0, Measure: 0, Beat: beat, BeatSet: beatSet, Note: ScoreDeleste.Tap, TargetPos: pos}
+}
+
+func convert(t *testing.T, left []ScoreSingleHand.Note, profile ArmProfile.Profile) []CommandArm.Command {
+	commands, _, err := Converter.ConvertToCommandsWithProfile(left, nil, profile, 120.0, 0)
+	assert.NoError(t, err)
+	return commands
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("reachable chart", func(t *testing.T) {
+		profile := ArmProfile.Default()
+		commands := convert(t, []ScoreSingleHand.Note{tap(0, 4, 1), tap(1, 4, 3), tap(2, 4, 2)}, profile)
+		assert.Empty(t, Check(commands, profile))
+	})
+
+	t.Run("move cannot finish before the next press", func(t *testing.T) {
+		// 4レーンの移動に 500ms かかるが、次のノーツは 125ms 後
+		profile := ArmProfile.Default()
+		commands := convert(t, []ScoreSingleHand.Note{tap(0, 16, 1), tap(1, 16, 5)}, profile)
+
+		violations := Check(commands, profile)
+		assert.Len(t, violations, 1)
+		assert.Equal(t, MoveTooSlow, violations[0].Kind)
+		assert.Equal(t, 125, violations[0].TimeMs)
+		assert.Equal(t, CommandArm.Left, violations[0].Hand)
+		assert.Equal(t, &CommandArm.Source{Channel: 0, Measure: 0, Beat: 1, BeatSet: 16, Note: ScoreDeleste.Tap, ID: "0+1/16@5#0"}, violations[0].Source)
+		assert.Equal(t, "125 ms L ch0 0:1/16 Tap [0+1/16@5#0]: move too slow (arrives at 5C 385.0 ms late)", violations[0].String())
+	})
+
+	t.Run("press shorter than the minimum on-time", func(t *testing.T) {
+		// コマンドは既定値 (10ms) で作り、30ms 必要な腕で確認する
+		commands := convert(t, []ScoreSingleHand.Note{tap(0, 4, 2)}, ArmProfile.Default())
+		profile := ArmProfile.Default()
+		profile.Solenoid.MinPressMs = 30
+
+		violations := Check(commands, profile)
+		assert.Len(t, violations, 1)
+		assert.Equal(t, PressTooShort, violations[0].Kind)
+		assert.Equal(t, 0, violations[0].TimeMs)
+		assert.Equal(t, "held 10 ms, needs 30 ms", violations[0].Detail)
+	})
+}
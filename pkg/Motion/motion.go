@@ -0,0 +1,149 @@
+package Motion
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+// Overload は最大速度・最大加速度では到着期限に間に合わない移動です
+type Overload struct {
+	TimeMs       int             // 動き始める時刻 (ミリ秒)
+	Hand         CommandArm.Hand // どちらの手か
+	From, To     CommandArm.Lane
+	WindowMs     int                // 動き始められる時刻から到着期限までの時間
+	TravelMs     int                // 最大速度・最大加速度で移動にかかる時間
+	Acceleration float64            // 期限に間に合わせるのに必要な加速度 (レーン/s²、速度の上限は考えない)
+	Source       *CommandArm.Source // 元になったノーツ (不明な場合は nil)
+}
+
+func (o Overload) String() string {
+	source := "unknown note"
+	if o.Source != nil {
+		source = o.Source.String()
+	}
+	return fmt.Sprintf("%d ms %s %s %s->%s: needs %.1f lanes/s^2 (travel %d ms, window %d ms)", o.TimeMs, o.Hand, source, o.From, o.To, o.Acceleration, o.TravelMs, o.WindowMs)
+}
+
+// Plan はすべての移動の出発時刻と到着時刻 (endTime) を腕の動作特性から決め直します
+// 到着期限は endTime が指定されていればその時刻、無ければ次の移動までに押す時刻とします
+// 期限のある移動は間に合う範囲でできるだけ遅く動き始め、endTime を期限にします
+// 押している間の移動 (フリック・スライド) と期限の無い移動 (待機位置へ戻るなど) は指令された時刻に動き始めます
+// 移動は前の移動が終わってから始めるものとし、期限に間に合わない移動は Overload として返します
+// 両手のコマンドが混ざっていても構いません (手ごとに決め直し、時刻順に並べて返します)
+func Plan(commands []CommandArm.Command, profile ArmProfile.Profile) ([]CommandArm.Command, []Overload) {
+	result := []CommandArm.Command{}
+	overloads := []Overload{}
+	for _, hand := range []CommandArm.Hand{CommandArm.Left, CommandArm.Right} {
+		planned, o := planHand(commandsOf(commands, hand), hand, profile)
+		result = append(result, planned...)
+		overloads = append(overloads, o...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TimeMs() < result[j].TimeMs()
+	})
+	sort.SliceStable(overloads, func(i, j int) bool {
+		return overloads[i].TimeMs < overloads[j].TimeMs
+	})
+	return result, overloads
+}
+
+// commandsOf は hand のコマンドを時刻順に取り出します
+func commandsOf(commands []CommandArm.Command, hand CommandArm.Hand) []CommandArm.Command {
+	result := []CommandArm.Command{}
+	for _, command := range commands {
+		if CommandArm.HandOf(command) == hand {
+			result = append(result, command)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TimeMs() < result[j].TimeMs()
+	})
+	return result
+}
+
+func planHand(commands []CommandArm.Command, hand CommandArm.Hand, profile ArmProfile.Profile) ([]CommandArm.Command, []Overload) {
+	result := make([]CommandArm.Command, 0, len(commands))
+	overloads := []Overload{}
+	position := profile.HomeOf(hand)
+	arrivalMs := math.MinInt // 直前の移動が終わる時刻
+	pressed := map[int]bool{}
+
+	for i, command := range commands {
+		switch c := command.(type) {
+		case *CommandArm.CommandSolenoid:
+			if c.State() {
+				pressed[c.Actuator()] = true
+			} else {
+				delete(pressed, c.Actuator())
+			}
+			result = append(result, c)
+		case *CommandArm.CommandMove:
+			travelMs := int(math.Ceil(profile.TravelMs(position, c.Lane()) - 1e-9))
+			earliestMs := max(c.TimeMs(), arrivalMs)
+			deadlineMs, hasDeadline := c.EndTime()
+			if !hasDeadline {
+				deadlineMs, hasDeadline = nextPressMs(commands[i+1:])
+			}
+
+			departureMs := earliestMs
+			if hasDeadline && len(pressed) == 0 {
+				departureMs = max(earliestMs, deadlineMs-travelMs)
+			}
+			endMs := departureMs + travelMs
+			switch {
+			case hasDeadline && endMs > deadlineMs:
+				windowMs := deadlineMs - earliestMs
+				overloads = append(overloads, Overload{
+					TimeMs:       departureMs,
+					Hand:         hand,
+					From:         position,
+					To:           c.Lane(),
+					WindowMs:     windowMs,
+					TravelMs:     travelMs,
+					Acceleration: requiredAcceleration(ArmProfile.LaneDistance(position, c.Lane()), windowMs),
+					Source:       CommandArm.SourceOf(c),
+				})
+			case hasDeadline:
+				endMs = deadlineMs
+			}
+
+			result = append(result, CommandArm.Reschedule(c, departureMs, endMs))
+			position = c.Lane()
+			arrivalMs = endMs
+		default:
+			result = append(result, command)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TimeMs() < result[j].TimeMs()
+	})
+	return result, overloads
+}
+
+// nextPressMs は次の移動までに押す時刻を返します
+func nextPressMs(commands []CommandArm.Command) (int, bool) {
+	for _, command := range commands {
+		switch c := command.(type) {
+		case *CommandArm.CommandMove:
+			return 0, false
+		case *CommandArm.CommandSolenoid:
+			if c.State() {
+				return c.TimeMs(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// requiredAcceleration は distance レーンを windowMs で加速・減速して移動するのに必要な加速度を返します
+func requiredAcceleration(distance float64, windowMs int) float64 {
+	if windowMs <= 0 {
+		return math.Inf(1)
+	}
+	seconds := float64(windowMs) / 1000
+	return 4 * distance / (seconds * seconds)
+}
@@ -0,0 +1,113 @@
+package Motion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+func messages(commands []CommandArm.Command) []string {
+	result := []string{}
+	for _, c := range commands {
+		result = append(result, c.Message())
+	}
+	return result
+}
+
+func TestPlan(t *testing.T) {
+	t.Run("moves start as late as possible", func(t *testing.T) {
+		// LL -> 2C は 267ms、2C -> 5C は 400ms、5C -> LL は 567ms
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMove(0, CommandArm.Left, CommandArm.Lane2),
+			CommandArm.NewCommandSolenoid(500, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(510, CommandArm.Left, false),
+			CommandArm.NewCommandMove(510, CommandArm.Left, CommandArm.Lane5),
+			CommandArm.NewCommandSolenoid(1000, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(1010, CommandArm.Left, false),
+			CommandArm.NewCommandMove(1010, CommandArm.Left, CommandArm.LeftEdge),
+		}
+		planned, overloads := Plan(commands, ArmProfile.Default())
+		assert.Empty(t, overloads)
+		assert.Equal(t, []string{
+			"M 233 L 2C 500",
+			"S 500 L ON",
+			"S 510 L OF",
+			"M 600 L 5C 1000",
+			"S 1000 L ON",
+			"S 1010 L OF",
+			"M 1010 L LL 1577",
+		}, messages(planned))
+	})
+
+	t.Run("moves while pressed keep their departure", func(t *testing.T) {
+		// 2R へのフリックは 116ms で着くが、到着時刻の指定どおり 750ms まで掛けて払う
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(100, CommandArm.Left, CommandArm.Lane2, 600),
+			CommandArm.NewCommandSolenoid(600, CommandArm.Left, true),
+			CommandArm.NewCommandMoveUntil(600, CommandArm.Left, CommandArm.Lane2Right, 750),
+			CommandArm.NewCommandSolenoid(760, CommandArm.Left, false),
+		}
+		planned, overloads := Plan(commands, ArmProfile.Default())
+		assert.Empty(t, overloads)
+		assert.Equal(t, []string{
+			"M 333 L 2C 600",
+			"S 600 L ON",
+			"M 600 L 2R 750",
+			"S 760 L OF",
+		}, messages(planned))
+	})
+
+	t.Run("deadline at 0 ms", func(t *testing.T) {
+		// 音源の先頭が到着期限の移動は、次に押す時刻ではなく 0ms に間に合わせる
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(-500, CommandArm.Left, CommandArm.Lane2, 0),
+			CommandArm.NewCommandSolenoid(500, CommandArm.Left, true),
+		}
+		planned, overloads := Plan(commands, ArmProfile.Default())
+		assert.Empty(t, overloads)
+		assert.Equal(t, []string{"M -267 L 2C 0", "S 500 L ON"}, messages(planned))
+	})
+
+	t.Run("move that cannot arrive in time", func(t *testing.T) {
+		source := CommandArm.Source{Channel: 0, Measure: 0, Beat: 1, BeatSet: 16}
+		commands := []CommandArm.Command{
+			CommandArm.WithSource(CommandArm.NewCommandMove(0, CommandArm.Left, CommandArm.Lane5), source),
+			CommandArm.NewCommandSolenoid(100, CommandArm.Left, true),
+		}
+		planned, overloads := Plan(commands, ArmProfile.Default())
+		assert.Equal(t, "M 0 L 5C 567 <- ch0 0:1/16 None", CommandArm.Describe(planned[0]))
+		assert.Len(t, overloads, 1)
+		assert.Equal(t, 100, overloads[0].WindowMs)
+		assert.Equal(t, 567, overloads[0].TravelMs)
+		assert.InDelta(t, 1866.7, overloads[0].Acceleration, 0.1)
+		assert.Equal(t, &source, overloads[0].Source)
+		assert.Equal(t, "0 ms L ch0 0:1/16 None LL->5C: needs 1866.7 lanes/s^2 (travel 567 ms, window 100 ms)", overloads[0].String())
+	})
+
+	t.Run("the next move waits for the previous one", func(t *testing.T) {
+		// 待機位置へ戻る途中の 5C -> LL (567ms) の後でしか 2C へ向かえない
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMove(0, CommandArm.Right, CommandArm.Lane1),
+			CommandArm.NewCommandMove(100, CommandArm.Right, CommandArm.Lane2),
+			CommandArm.NewCommandSolenoid(900, CommandArm.Right, true),
+		}
+		planned, overloads := Plan(commands, ArmProfile.Default())
+		assert.Empty(t, overloads)
+		// RR -> 1C は 567ms、1C -> 2C は 200ms
+		assert.Equal(t, []string{"M 0 R 1C 567", "M 700 R 2C 900", "S 900 R ON"}, messages(planned))
+	})
+
+	t.Run("both hands", func(t *testing.T) {
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMove(0, CommandArm.Left, CommandArm.Lane1),
+			CommandArm.NewCommandMove(0, CommandArm.Right, CommandArm.Lane5),
+			CommandArm.NewCommandSolenoid(500, CommandArm.Left, true),
+			CommandArm.NewCommandSolenoid(500, CommandArm.Right, true),
+		}
+		planned, _ := Plan(commands, ArmProfile.Default())
+		assert.Equal(t, []string{"M 336 L 1C 500", "M 336 R 5C 500", "S 500 L ON", "S 500 R ON"}, messages(planned))
+	})
+}
@@ -0,0 +1,155 @@
+package Optimizer
+
+import (
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+// Pass はコマンド列を同じ動作のまま短くする処理です
+// 入力は手ごとに時刻順であれば、両手が混ざっていても構いません
+type Pass func(commands []CommandArm.Command) []CommandArm.Command
+
+// DefaultPasses は Optimize で使う既定の処理の順番です
+var DefaultPasses = []Pass{
+	MergeSameTimeMoves,
+	RemoveUselessParking,
+	RemoveRedundantMoves,
+}
+
+// Optimize は passes を順に適用します (passes が無い場合は DefaultPasses)
+func Optimize(commands []CommandArm.Command, passes ...Pass) []CommandArm.Command {
+	if len(passes) == 0 {
+		passes = DefaultPasses
+	}
+	for _, pass := range passes {
+		commands = pass(commands)
+	}
+	return commands
+}
+
+// RemoveRedundantMoves は既にいるレーンへの移動を取り除きます
+// 最初の移動までは位置が分からないので、各手の最初の移動は残します
+func RemoveRedundantMoves(commands []CommandArm.Command) []CommandArm.Command {
+	result := []CommandArm.Command{}
+	position := map[CommandArm.Hand]CommandArm.Lane{}
+	for _, command := range commands {
+		if move, ok := command.(*CommandArm.CommandMove); ok {
+			if lane, known := position[move.Hand()]; known && lane == move.Lane() {
+				continue
+			}
+			position[move.Hand()] = move.Lane()
+		}
+		result = append(result, command)
+	}
+	return result
+}
+
+// MergeSameTimeMoves は同じ手の同じ時刻の移動が続く場合に、最後の移動だけを残します
+// 取り除いた移動の方が到着時刻が早い場合は、その到着時刻と元になったノーツを残した移動に引き継ぎます
+func MergeSameTimeMoves(commands []CommandArm.Command) []CommandArm.Command {
+	next := nextOfSameHand(commands)
+	carried := map[int]*CommandArm.CommandMove{} // 添字 -> 同じ時刻の前の移動をまとめたもの
+	result := []CommandArm.Command{}
+	for i, command := range commands {
+		if move, ok := command.(*CommandArm.CommandMove); ok {
+			if earlier, ok := carried[i]; ok {
+				move = earliestDeadline(move, earlier)
+			}
+			if next[i] >= 0 {
+				if following, ok := commands[next[i]].(*CommandArm.CommandMove); ok && following.TimeMs() == move.TimeMs() {
+					carried[next[i]] = move
+					continue
+				}
+			}
+			command = move
+		}
+		result = append(result, command)
+	}
+	return result
+}
+
+// earliestDeadline は move に earlier の方が早い到着時刻があれば、それと earlier の元になったノーツを付けた移動を返します
+func earliestDeadline(move, earlier *CommandArm.CommandMove) *CommandArm.CommandMove {
+	earlierEndMs, earlierHasEnd := earlier.EndTime()
+	endMs, hasEnd := move.EndTime()
+	if !earlierHasEnd || (hasEnd && endMs <= earlierEndMs) {
+		return move
+	}
+	merged := CommandArm.NewCommandMoveUntil(move.TimeMs(), move.Hand(), move.Lane(), earlierEndMs)
+	if source := CommandArm.SourceOf(earlier); source != nil {
+		merged = CommandArm.WithSource(merged, *source)
+	} else if source := CommandArm.SourceOf(move); source != nil {
+		merged = CommandArm.WithSource(merged, *source)
+	}
+	return merged.(*CommandArm.CommandMove)
+}
+
+// RemoveUselessParking は押していない間の到着時刻の指定が無い移動 (ノーツの間に待たせる位置への移動) のうち、
+// 押さずに次の移動をするものを取り除きます
+// 腕は次の移動の時刻までその場で待ちます
+// 次の移動は待たせる位置から動く前提で時刻が決まっているので、その場の方が次の移動先から遠い場合
+// (または手の位置が分からない場合) は取り除きません
+func RemoveUselessParking(commands []CommandArm.Command) []CommandArm.Command {
+	next := nextOfSameHand(commands)
+	pressed := map[CommandArm.Hand]map[int]bool{} // 手ごとの押しているソレノイド
+	position := map[CommandArm.Hand]CommandArm.Lane{}
+	result := []CommandArm.Command{}
+	for i, command := range commands {
+		switch c := command.(type) {
+		case *CommandArm.CommandSolenoid:
+			if pressed[c.Hand()] == nil {
+				pressed[c.Hand()] = map[int]bool{}
+			}
+			if c.State() {
+				pressed[c.Hand()][c.Actuator()] = true
+			} else {
+				delete(pressed[c.Hand()], c.Actuator())
+			}
+		case *CommandArm.CommandMove:
+			if _, hasEnd := c.EndTime(); !hasEnd && len(pressed[c.Hand()]) == 0 && next[i] >= 0 {
+				following, ok := commands[next[i]].(*CommandArm.CommandMove)
+				lane, known := position[c.Hand()]
+				if ok && known && laneDistance(lane, following.Lane()) <= laneDistance(c.Lane(), following.Lane()) {
+					continue
+				}
+			}
+			position[c.Hand()] = c.Lane()
+		}
+		result = append(result, command)
+	}
+	return result
+}
+
+// MergeHands は左右のコマンド列を1本の時刻順の列にまとめます
+// 同じ時刻では左手を先にし、それぞれの手の中の順序は保ちます
+func MergeHands(left, right []CommandArm.Command) []CommandArm.Command {
+	result := append(append([]CommandArm.Command{}, left...), right...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TimeMs() < result[j].TimeMs()
+	})
+	return result
+}
+
+// laneDistance は2つの位置の間の距離 (CommandArm.Lane の刻み) を返します
+func laneDistance(from, to CommandArm.Lane) int {
+	if from > to {
+		return int(from - to)
+	}
+	return int(to - from)
+}
+
+// nextOfSameHand は各コマンドについて、同じ手の次のコマンドの添字 (無ければ -1) を返します
+func nextOfSameHand(commands []CommandArm.Command) []int {
+	next := make([]int, len(commands))
+	last := map[CommandArm.Hand]int{}
+	for i := len(commands) - 1; i >= 0; i-- {
+		hand := CommandArm.HandOf(commands[i])
+		next[i] = -1
+		if j, ok := last[hand]; ok {
+			next[i] = j
+		}
+		last[hand] = i
+	}
+	return next
+}
@@ -0,0 +1,225 @@
+package Optimizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/Motion"
+)
+
+func messages(commands []CommandArm.Command) []string {
+	result := []string{}
+	for _, c := range commands {
+		result = append(result, c.Message())
+	}
+	return result
+}
+
+func move(time int, hand CommandArm.Hand, lane CommandArm.Lane) CommandArm.Command {
+	return CommandArm.NewCommandMove(time, hand, lane)
+}
+
+func solenoid(time int, hand CommandArm.Hand, state bool) CommandArm.Command {
+	return CommandArm.NewCommandSolenoid(time, hand, state)
+}
+
+func TestRemoveRedundantMoves(t *testing.T) {
+	commands := []CommandArm.Command{
+		CommandArm.NewCommandMove(-267, CommandArm.Left, CommandArm.Lane2),
+		solenoid(0, CommandArm.Left, true),
+		solenoid(10, CommandArm.Left, false),
+		move(10, CommandArm.Left, CommandArm.Lane2),
+		move(10, CommandArm.Right, CommandArm.Lane2),
+		solenoid(500, CommandArm.Left, true),
+		solenoid(510, CommandArm.Left, false),
+		move(510, CommandArm.Left, CommandArm.Lane5),
+	}
+
+	assert.Equal(t, []string{
+		"M -267 L 2C",
+		"S 0 L ON",
+		"S 10 L OF",
+		"M 10 R 2C",
+		"S 500 L ON",
+		"S 510 L OF",
+		"M 510 L 5C",
+	}, messages(RemoveRedundantMoves(commands)))
+}
+
+func TestMergeSameTimeMoves(t *testing.T) {
+	commands := []CommandArm.Command{
+		solenoid(10, CommandArm.Left, false),
+		move(10, CommandArm.Left, CommandArm.Lane2),
+		move(10, CommandArm.Right, CommandArm.Lane4),
+		move(10, CommandArm.Left, CommandArm.Lane3),
+		move(20, CommandArm.Left, CommandArm.Lane1),
+	}
+
+	assert.Equal(t, []string{
+		"S 10 L OF",
+		"M 10 R 4C",
+		"M 10 L 3C",
+		"M 20 L 1C",
+	}, messages(MergeSameTimeMoves(commands)))
+}
+
+func TestMergeSameTimeMovesKeepsDeadline(t *testing.T) {
+	source := CommandArm.Source{Channel: 1, Measure: 0, Beat: 1, BeatSet: 4, ID: "0+1/4@3#1"}
+	commands := []CommandArm.Command{
+		CommandArm.WithSource(CommandArm.NewCommandMoveUntil(10, CommandArm.Left, CommandArm.Lane2, 300), source),
+		move(10, CommandArm.Left, CommandArm.Lane3),
+		CommandArm.NewCommandMoveUntil(20, CommandArm.Left, CommandArm.Lane4, 500),
+		CommandArm.NewCommandMoveUntil(20, CommandArm.Left, CommandArm.Lane5, 400),
+	}
+
+	// 早い方の到着時刻と、その元になったノーツを残す
+	merged := MergeSameTimeMoves(commands)
+	assert.Equal(t, []string{"M 10 L 3C 300", "M 20 L 5C 400"}, messages(merged))
+	assert.Equal(t, &source, CommandArm.SourceOf(merged[0]))
+	assert.Nil(t, CommandArm.SourceOf(merged[1]))
+}
+
+func TestRemoveUselessParking(t *testing.T) {
+	commands := []CommandArm.Command{
+		CommandArm.NewCommandMoveUntil(-267, CommandArm.Left, CommandArm.Lane2, 0),
+		solenoid(0, CommandArm.Left, true),
+		solenoid(10, CommandArm.Left, false),
+		move(10, CommandArm.Left, CommandArm.LeftEdge),
+		move(10, CommandArm.Right, CommandArm.RightEdge),
+		CommandArm.NewCommandMoveUntil(733, CommandArm.Left, CommandArm.Lane2, 1000),
+		solenoid(1000, CommandArm.Left, true),
+		solenoid(1010, CommandArm.Left, false),
+		move(1010, CommandArm.Left, CommandArm.LeftEdge),
+	}
+
+	// 最後の退避と、押さずに終わる右手の退避は残る
+	assert.Equal(t, []string{
+		"M -267 L 2C 0",
+		"S 0 L ON",
+		"S 10 L OF",
+		"M 10 R RR",
+		"M 733 L 2C 1000",
+		"S 1000 L ON",
+		"S 1010 L OF",
+		"M 1010 L LL",
+	}, messages(RemoveUselessParking(commands)))
+
+	t.Run("parking away from the edges", func(t *testing.T) {
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(-267, CommandArm.Left, CommandArm.Lane3, 0),
+			solenoid(0, CommandArm.Left, true),
+			solenoid(10, CommandArm.Left, false),
+			move(10, CommandArm.Left, CommandArm.Lane1),
+			CommandArm.NewCommandMoveUntil(733, CommandArm.Left, CommandArm.Lane3, 1000),
+			solenoid(1000, CommandArm.Left, true),
+			// 押したまま払う移動は残る
+			move(1000, CommandArm.Left, CommandArm.Lane3Right),
+			move(1100, CommandArm.Left, CommandArm.Lane4),
+			solenoid(1110, CommandArm.Left, false),
+		}
+
+		assert.Equal(t, []string{
+			"M -267 L 3C 0",
+			"S 0 L ON",
+			"S 10 L OF",
+			"M 733 L 3C 1000",
+			"S 1000 L ON",
+			"M 1000 L 3R",
+			"M 1100 L 4C",
+			"S 1110 L OF",
+		}, messages(RemoveUselessParking(commands)))
+	})
+
+	t.Run("parking closer to the next note", func(t *testing.T) {
+		// 5C を押して LL に戻り、1C へは LL からの移動時間 (164ms) だけ前に動き始める
+		commands := []CommandArm.Command{
+			CommandArm.NewCommandMoveUntil(433, CommandArm.Left, CommandArm.Lane5, 1000),
+			solenoid(1000, CommandArm.Left, true),
+			solenoid(1010, CommandArm.Left, false),
+			move(1010, CommandArm.Left, CommandArm.LeftEdge),
+			CommandArm.NewCommandMoveUntil(2336, CommandArm.Left, CommandArm.Lane1, 2500),
+			solenoid(2500, CommandArm.Left, true),
+			solenoid(2510, CommandArm.Left, false),
+		}
+
+		// 5C に残ると 1C まで 500ms かかり間に合わないので、退避は残る
+		optimized := RemoveUselessParking(commands)
+		assert.Equal(t, messages(commands), messages(optimized))
+		_, overloads := Motion.Plan(Optimize(commands), ArmProfile.Default())
+		assert.Empty(t, overloads)
+	})
+
+	t.Run("unknown position", func(t *testing.T) {
+		commands := []CommandArm.Command{
+			solenoid(0, CommandArm.Left, true),
+			solenoid(10, CommandArm.Left, false),
+			move(10, CommandArm.Left, CommandArm.LeftEdge),
+			CommandArm.NewCommandMoveUntil(733, CommandArm.Left, CommandArm.Lane2, 1000),
+		}
+
+		// 最初の移動より前は腕がどこにいるか分からないので、退避は残る
+		assert.Equal(t, messages(commands), messages(RemoveUselessParking(commands)))
+	})
+}
+
+func TestMergeHands(t *testing.T) {
+	left := []CommandArm.Command{
+		solenoid(0, CommandArm.Left, true),
+		solenoid(10, CommandArm.Left, false),
+		move(10, CommandArm.Left, CommandArm.Lane3),
+	}
+	right := []CommandArm.Command{
+		solenoid(5, CommandArm.Right, true),
+		solenoid(10, CommandArm.Right, false),
+	}
+
+	assert.Equal(t, []string{
+		"S 0 L ON",
+		"S 5 R ON",
+		"S 10 L OF",
+		"M 10 L 3C",
+		"S 10 R OF",
+	}, messages(MergeHands(left, right)))
+}
+
+func TestOptimize(t *testing.T) {
+	commands := []CommandArm.Command{
+		CommandArm.NewCommandMove(-267, CommandArm.Left, CommandArm.Lane2),
+		solenoid(0, CommandArm.Left, true),
+		solenoid(10, CommandArm.Left, false),
+		move(10, CommandArm.Left, CommandArm.LeftEdge),
+		CommandArm.NewCommandMoveUntil(733, CommandArm.Left, CommandArm.Lane2, 1000),
+		solenoid(1000, CommandArm.Left, true),
+		solenoid(1010, CommandArm.Left, false),
+		move(1010, CommandArm.Left, CommandArm.LeftEdge),
+	}
+
+	// 退避を取り除くと次の移動は同じレーンへの移動になるので、それも取り除かれる
+	assert.Equal(t, []string{
+		"M -267 L 2C",
+		"S 0 L ON",
+		"S 10 L OF",
+		"S 1000 L ON",
+		"S 1010 L OF",
+		"M 1010 L LL",
+	}, messages(Optimize(commands)))
+}
+
+func TestOptimizeKeepsSources(t *testing.T) {
+	source := CommandArm.Source{Channel: 1, Measure: 2, Beat: 1, BeatSet: 4, ID: "2+1/4@3#1"}
+	commands := []CommandArm.Command{
+		solenoid(0, CommandArm.Left, true),
+		solenoid(10, CommandArm.Left, false),
+		move(10, CommandArm.Left, CommandArm.Lane2),
+		CommandArm.WithSource(move(10, CommandArm.Left, CommandArm.Lane3), source),
+		CommandArm.WithSource(solenoid(500, CommandArm.Left, true), source),
+	}
+
+	optimized := Optimize(commands)
+	assert.Equal(t, []string{"S 0 L ON", "S 10 L OF", "M 10 L 3C", "S 500 L ON"}, messages(optimized))
+	assert.Equal(t, &source, CommandArm.SourceOf(optimized[2]))
+	assert.Equal(t, &source, CommandArm.SourceOf(optimized[3]))
+}
@@ -0,0 +1,101 @@
+package ScoreDeleste
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ハッシュの正規形のバージョン (正規形を変えたら上げる)
+const hashVersion = "score-hash-v2"
+
+type hashNote struct {
+	timeUs    int64 // 音源上の時刻 (マイクロ秒)
+	targetPos int
+	startPos  int
+	note      NoteType
+	channel   int
+}
+
+// Hash は譜面の内容から計算した指紋 (SHA-256 の16進文字列) を返します
+// 音源上の時刻・レーン・ノートタイプ・ロングやスライドの連結・テンポマップだけを対象にするため、
+// 行の順序、タイミング文字列の分割数、チャンネル番号の振り方 (偶奇を除く)、メタデータは結果に影響しません
+// チャンネルの偶奇は ScoreSingleHand.ChannelParity の手の振り分けを変えるので、変換したコマンド列のキャッシュのキーにも使えます
+func (s *Score) Hash() string {
+	h := sha256.New()
+	fmt.Fprintln(h, hashVersion)
+
+	// テンポマップ (直前と同じテンポへの変化は除く)
+	lastBPM := 0.0
+	for _, change := range s.TempoMap() {
+		if change.BPM == lastBPM {
+			continue
+		}
+		lastBPM = change.BPM
+		fmt.Fprintf(h, "T %d %s\n", toMicroseconds(s.AudioTimeMs(change.Measure, change.Beat, change.BeatSet)), formatBPM(change.BPM))
+	}
+
+	notes := []hashNote{}
+	for _, note := range s.Notes {
+		count := 0
+		for beat, noteType := range note.Note {
+			if noteType == None {
+				continue
+			}
+			n := hashNote{
+				timeUs:  toMicroseconds(s.AudioTimeMs(note.Measure, beat, len(note.Note))),
+				note:    noteType,
+				channel: note.Channel,
+			}
+			if count < len(note.TargetPos) {
+				n.targetPos = note.TargetPos[count]
+			}
+			if count < len(note.StartPos) {
+				n.startPos = note.StartPos[count]
+			}
+			notes = append(notes, n)
+			count++
+		}
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		switch {
+		case a.timeUs != b.timeUs:
+			return a.timeUs < b.timeUs
+		case a.targetPos != b.targetPos:
+			return a.targetPos < b.targetPos
+		case a.note != b.note:
+			return a.note < b.note
+		case a.startPos != b.startPos:
+			return a.startPos < b.startPos
+		default:
+			return a.channel < b.channel
+		}
+	})
+
+	// チャンネル番号は連結関係と偶奇だけが意味を持つので、偶奇ごとに初出順に振り直す
+	canonical := map[int]int{}
+	counts := [2]int{}
+	for _, n := range notes {
+		channel, ok := canonical[n.channel]
+		if !ok {
+			parity := n.channel & 1
+			channel = 2*counts[parity] + parity
+			counts[parity]++
+			canonical[n.channel] = channel
+		}
+		fmt.Fprintf(h, "N %d %d %d %d %d\n", n.timeUs, n.targetPos, n.startPos, n.note, channel)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func toMicroseconds(ms float64) int64 {
+	return int64(math.Round(ms * 1000))
+}
+
+func formatBPM(bpm float64) string {
+	return fmt.Sprintf("%.6f", bpm)
+}
@@ -0,0 +1,66 @@
+package ScoreDeleste
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	parse := func(chart string) *Score {
+		score, err := Parse(strings.NewReader(chart))
+		assert.NoError(t, err)
+		return score
+	}
+
+	base := parse(`#Title test
+#BPM 120
+#0,000:2040:1:3
+#1,000:0203:5:5
+#0,001:0020:3
+`)
+
+	t.Run("formatting does not change the hash", func(t *testing.T) {
+		// 行の順序・分割数・チャンネル番号・メタデータが違うだけの譜面
+		same := parse(`#Title another title
+#Composer someone
+#BPM 120.0
+#3,000:00200030:5:5
+#2,001:02:3
+#2,000:20004000:1:3
+`)
+		assert.Equal(t, base.Hash(), same.Hash())
+	})
+
+	t.Run("offsets that cancel out do not change the hash", func(t *testing.T) {
+		shifted := parse(`#BPM 120
+#Offset 100
+#SongOffset 100
+#0,000:2040:1:3
+#1,000:0203:5:5
+#0,001:0020:3
+`)
+		assert.Equal(t, base.Hash(), shifted.Hash())
+	})
+
+	t.Run("content changes the hash", func(t *testing.T) {
+		charts := []string{
+			// レーン違い
+			"#BPM 120\n#0,000:2040:2:3\n#1,000:0203:5:5\n#0,001:0020:3\n",
+			// タイミング違い
+			"#BPM 120\n#0,000:2004:1:3\n#1,000:0203:5:5\n#0,001:0020:3\n",
+			// ノートタイプ違い
+			"#BPM 120\n#0,000:2040:1:3\n#1,000:0201:5:5\n#0,001:0020:3\n",
+			// テンポ違い
+			"#BPM 121\n#0,000:2040:1:3\n#1,000:0203:5:5\n#0,001:0020:3\n",
+			// ロングの終点が別チャンネル
+			"#BPM 120\n#0,000:2040:1:3\n#1,000:0203:5:5\n#2,001:0020:3\n",
+			// チャンネルの偶奇違い (ChannelParity では手が入れ替わる)
+			"#BPM 120\n#1,000:2040:1:3\n#0,000:0203:5:5\n#1,001:0020:3\n",
+		}
+		for _, chart := range charts {
+			assert.NotEqual(t, base.Hash(), parse(chart).Hash(), chart)
+		}
+	})
+}
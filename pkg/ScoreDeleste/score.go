@@ -3,6 +3,7 @@ package ScoreDeleste
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -10,8 +11,9 @@ import (
 )
 
 type Score struct {
-	Header Header
-	Notes  []Note
+	Header     Header
+	Notes      []Note
+	BPMChanges []BPMChange // 譜面途中のテンポ変化 (Header.BPM が初期値)
 }
 
 type Difficulty int
@@ -63,6 +65,25 @@ const (
 	Slide
 )
 
+func (e NoteType) String() string {
+	switch e {
+	case None:
+		return "None"
+	case LeftFlick:
+		return "LeftFlick"
+	case Tap:
+		return "Tap"
+	case RightFlick:
+		return "RightFlick"
+	case LongStart:
+		return "LongStart"
+	case Slide:
+		return "Slide"
+	default:
+		return fmt.Sprintf("NoteType(%d)", int(e))
+	}
+}
+
 func (e *NoteType) UnmarshalText(text []byte) error {
 	switch string(text) {
 	case "None":
@@ -91,6 +112,7 @@ type Note struct {
 	TargetPos []int      // 目標位置 (1-5)
 }
 
+// ParseScore は Deleste 形式の譜面ファイルを読み込みます
 func ParseScore(filepath string) (*Score, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -98,27 +120,19 @@ func ParseScore(filepath string) (*Score, error) {
 	}
 	defer file.Close()
 
-	// BOMチェック用のバッファ
-	bom := make([]byte, 3)
-	if _, err := file.Read(bom); err != nil {
-		return nil, err
-	}
-	// ファイルポインタを先頭に戻す
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, err
-	}
+	return Parse(file)
+}
+
+// Parse は Deleste 形式の譜面を読み込みます
+func Parse(r io.Reader) (*Score, error) {
+	reader := bufio.NewReader(r)
 
-	var reader *bufio.Reader
 	// UTF-8 BOMチェック (0xEF,0xBB,0xBF)
-	if bom[0] == 0xEF && bom[1] == 0xBB && bom[2] == 0xBF {
+	if bom, err := reader.Peek(3); err == nil && bom[0] == 0xEF && bom[1] == 0xBB && bom[2] == 0xBF {
 		// BOMがある場合は3バイトスキップ
-		if _, err := file.Seek(3, 0); err != nil {
+		if _, err := reader.Discard(3); err != nil {
 			return nil, err
 		}
-		reader = bufio.NewReader(file)
-	} else {
-		// BOMがない場合はそのまま読み込み
-		reader = bufio.NewReader(file)
 	}
 
 	score := &Score{}
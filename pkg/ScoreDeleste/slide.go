@@ -0,0 +1,78 @@
+package ScoreDeleste
+
+import "sort"
+
+// SlidePoint はスライドの始点・中継点・終点を表します
+type SlidePoint struct {
+	Measure   int      // 小節数
+	Beat      int      // 小節内の位置
+	BeatSet   int      // 小節の分割数
+	Note      NoteType // Slide または終端のフリック
+	TargetPos int      // 目標位置 (1-5)
+}
+
+// SlidePath は同じチャンネルで連続するスライドノーツを1本の経路にまとめたものです
+type SlidePath struct {
+	Channel int
+	Points  []SlidePoint
+}
+
+// SlidePaths は譜面中のスライドを経路ごとに抽出します
+// 同じチャンネルで連続する Slide を1本の経路とし、直後のフリックは終端として経路に含めます
+func (s *Score) SlidePaths() []SlidePath {
+	byChannel := map[int][]SlidePoint{}
+	channels := []int{}
+	for _, note := range s.Notes {
+		count := 0
+		for beat, noteType := range note.Note {
+			if noteType == None {
+				continue
+			}
+			point := SlidePoint{
+				Measure: note.Measure,
+				Beat:    beat,
+				BeatSet: len(note.Note),
+				Note:    noteType,
+			}
+			if count < len(note.TargetPos) {
+				point.TargetPos = note.TargetPos[count]
+			}
+			if _, ok := byChannel[note.Channel]; !ok {
+				channels = append(channels, note.Channel)
+			}
+			byChannel[note.Channel] = append(byChannel[note.Channel], point)
+			count++
+		}
+	}
+	sort.Ints(channels)
+
+	paths := []SlidePath{}
+	for _, channel := range channels {
+		points := byChannel[channel]
+		sort.SliceStable(points, func(i, j int) bool {
+			return CompareBeats(points[i].Measure, points[i].Beat, points[i].BeatSet, points[j].Measure, points[j].Beat, points[j].BeatSet) < 0
+		})
+
+		var current *SlidePath
+		for _, point := range points {
+			switch {
+			case point.Note == Slide:
+				if current == nil {
+					current = &SlidePath{Channel: channel}
+				}
+				current.Points = append(current.Points, point)
+			case current != nil && (point.Note == LeftFlick || point.Note == RightFlick):
+				current.Points = append(current.Points, point)
+				paths = append(paths, *current)
+				current = nil
+			case current != nil:
+				paths = append(paths, *current)
+				current = nil
+			}
+		}
+		if current != nil {
+			paths = append(paths, *current)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,66 @@
+package ScoreDeleste
+
+import "sort"
+
+// BPMChange は譜面途中のテンポ変化を表します
+type BPMChange struct {
+	Measure int     // 小節数
+	Beat    int     // 小節内の位置
+	BeatSet int     // 小節の分割数
+	BPM     float64 // 変化後のテンポ
+}
+
+// MeasurePos は変化位置を小節単位の実数で返します
+func (c BPMChange) MeasurePos() float64 {
+	if c.BeatSet <= 0 {
+		return float64(c.Measure)
+	}
+	return float64(c.Measure) + float64(c.Beat)/float64(c.BeatSet)
+}
+
+// TempoMap は Header.BPM を 0 小節目の値として含む、位置順に並んだテンポ変化の一覧を返します
+func (s *Score) TempoMap() []BPMChange {
+	changes := make([]BPMChange, 0, len(s.BPMChanges)+1)
+	changes = append(changes, BPMChange{Measure: 0, Beat: 0, BeatSet: 1, BPM: s.Header.BPM})
+	changes = append(changes, s.BPMChanges...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].MeasurePos() < changes[j].MeasurePos()
+	})
+	return changes
+}
+
+// TimeMs は measure + beat/beatSet 小節目の、譜面先頭からの時間 (ミリ秒) を返します
+// 1小節は4拍として扱います
+func (s *Score) TimeMs(measure, beat, beatSet int) float64 {
+	target := float64(measure)
+	if beatSet > 0 {
+		target += float64(beat) / float64(beatSet)
+	}
+
+	changes := s.TempoMap()
+	timeMs := 0.0
+	for i, change := range changes {
+		if change.BPM <= 0 {
+			continue
+		}
+		end := target
+		if i+1 < len(changes) && changes[i+1].MeasurePos() < target {
+			end = changes[i+1].MeasurePos()
+		}
+		if end <= change.MeasurePos() {
+			continue
+		}
+		timeMs += (end - change.MeasurePos()) * 60000.0 * 4.0 / change.BPM
+		if end == target {
+			break
+		}
+	}
+	return timeMs
+}
+
+// AudioTimeMs は measure + beat/beatSet 小節目が音源ファイルの何ミリ秒目にあたるかを返します
+// Offset は 0 小節目を置くゲーム上の時刻、SongOffset は音源の再生を始めるゲーム上の時刻として扱い、
+// 音源上の時刻 = 譜面先頭からの時間 + Offset - SongOffset とします
+func (s *Score) AudioTimeMs(measure, beat, beatSet int) float64 {
+	return s.TimeMs(measure, beat, beatSet) + float64(s.Header.Offset-s.Header.SongOffset)
+}
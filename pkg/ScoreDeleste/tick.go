@@ -0,0 +1,141 @@
+package ScoreDeleste
+
+import "sort"
+
+// TickNote は譜面先頭からの tick で位置を表したノーツです
+// tick 単位の形式 (SUS, MIDI など) との変換に使います
+type TickNote struct {
+	Channel   int      // チャンネル番号
+	Tick      int      // 譜面先頭からの tick
+	Note      NoteType // ノートタイプ
+	StartPos  int      // 出現位置 (1-5)
+	TargetPos int      // 目標位置 (1-5)
+}
+
+// TickAt は measure + beat/beatSet 小節目を tick に変換します
+// 割り切れない位置は切り捨てます
+func TickAt(measure, beat, beatSet, ticksPerMeasure int) int {
+	if beatSet <= 0 {
+		return measure * ticksPerMeasure
+	}
+	return measure*ticksPerMeasure + beat*ticksPerMeasure/beatSet
+}
+
+// PositionAt は tick を小節数・小節内の位置・分割数に変換します
+// 分割数は tick と siblings をすべて表せる最小の値になります
+func PositionAt(tick, ticksPerMeasure int, siblings ...int) (int, int, int) {
+	g := GCD(ticksPerMeasure, tick%ticksPerMeasure)
+	for _, t := range siblings {
+		g = GCD(g, t%ticksPerMeasure)
+	}
+	return tick / ticksPerMeasure, (tick % ticksPerMeasure) / g, ticksPerMeasure / g
+}
+
+// BuildNotes は tick 単位のノーツをチャンネル・小節ごとの行にまとめます
+// 同じチャンネル・同じ tick に複数のノーツがある場合は後のものが優先されます
+func BuildNotes(notes []TickNote, ticksPerMeasure int) []Note {
+	type rowKey struct {
+		channel int
+		measure int
+	}
+	grouped := map[rowKey][]TickNote{}
+	keys := []rowKey{}
+	for _, n := range notes {
+		key := rowKey{n.Channel, n.Tick / ticksPerMeasure}
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+		}
+		grouped[key] = append(grouped[key], n)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].measure != keys[j].measure {
+			return keys[i].measure < keys[j].measure
+		}
+		return keys[i].channel < keys[j].channel
+	})
+
+	rows := []Note{}
+	for _, key := range keys {
+		row := grouped[key]
+		sort.SliceStable(row, func(i, j int) bool {
+			return row[i].Tick < row[j].Tick
+		})
+
+		ticks := make([]int, len(row))
+		for i, n := range row {
+			ticks[i] = n.Tick
+		}
+		_, _, beatSet := PositionAt(row[0].Tick, ticksPerMeasure, ticks...)
+
+		byBeat := make([]*TickNote, beatSet)
+		for i := range row {
+			_, beat, _ := PositionAt(row[i].Tick, ticksPerMeasure, ticks...)
+			byBeat[beat] = &row[i]
+		}
+
+		note := Note{
+			Channel: key.channel,
+			Measure: key.measure,
+			Note:    make([]NoteType, beatSet),
+		}
+		for beat, n := range byBeat {
+			if n == nil {
+				continue
+			}
+			note.Note[beat] = n.Note
+			note.StartPos = append(note.StartPos, n.StartPos)
+			note.TargetPos = append(note.TargetPos, n.TargetPos)
+		}
+		rows = append(rows, note)
+	}
+	return rows
+}
+
+// TickNotes は譜面のノーツを tick 単位の一覧に変換します
+func (s *Score) TickNotes(ticksPerMeasure int) []TickNote {
+	notes := []TickNote{}
+	for _, note := range s.Notes {
+		count := 0
+		for beat, noteType := range note.Note {
+			if noteType == None {
+				continue
+			}
+			n := TickNote{
+				Channel: note.Channel,
+				Tick:    TickAt(note.Measure, beat, len(note.Note), ticksPerMeasure),
+				Note:    noteType,
+			}
+			if count < len(note.TargetPos) {
+				n.TargetPos = note.TargetPos[count]
+			}
+			if count < len(note.StartPos) {
+				n.StartPos = note.StartPos[count]
+			} else {
+				n.StartPos = n.TargetPos
+			}
+			notes = append(notes, n)
+			count++
+		}
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Tick < notes[j].Tick
+	})
+	return notes
+}
+
+// CompareBeats は measure + beat/beatSet で表した2つの位置を比較し、a が先なら負、同時なら 0、後なら正を返します
+// 分割数が違っても、beat が beatSet 以上でも正しく比べます (分割数は 1 以上)
+func CompareBeats(measureA, beatA, beatSetA, measureB, beatB, beatSetB int) int {
+	return (measureA*beatSetA+beatA)*beatSetB - (measureB*beatSetB+beatB)*beatSetA
+}
+
+// GCD は a と b の最大公約数 (0 以上) を返します
+func GCD(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,78 @@
+package ScoreFormat
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreMIDI"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreOsu"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreSUS"
+)
+
+// 組み込みの形式を登録します
+func init() {
+	Register(Deleste{})
+	Register(SUS{})
+	Register(MIDI{})
+	Register(Osu{})
+}
+
+// Deleste はデレステ譜面 (Deleste) 形式です
+type Deleste struct{}
+
+var delesteNoteLine = regexp.MustCompile(`(?m)^#\d+,\d+:`)
+
+func (Deleste) Name() string         { return "deleste" }
+func (Deleste) Extensions() []string { return []string{".txt"} }
+func (Deleste) Sniff(head []byte) bool {
+	return delesteNoteLine.Match(bytes.TrimPrefix(head, []byte{0xEF, 0xBB, 0xBF}))
+}
+func (Deleste) Import(r io.Reader) (*ScoreDeleste.Score, error) {
+	return ScoreDeleste.Parse(r)
+}
+
+// SUS は Sliding Universal Score 形式です
+type SUS struct{}
+
+var susDataLine = regexp.MustCompile(`(?m)^#(\d{3}[0-9a-zA-Z]{2,3}|BPM[0-9a-zA-Z]{2})\s*:`)
+
+func (SUS) Name() string         { return "sus" }
+func (SUS) Extensions() []string { return []string{".sus"} }
+func (SUS) Sniff(head []byte) bool {
+	return susDataLine.Match(bytes.TrimPrefix(head, []byte{0xEF, 0xBB, 0xBF}))
+}
+func (SUS) Import(r io.Reader) (*ScoreDeleste.Score, error) {
+	return ScoreSUS.Parse(r)
+}
+
+// MIDI は Standard MIDI File 形式です
+type MIDI struct {
+	ImportOptions ScoreMIDI.ImportOptions
+	ExportOptions ScoreMIDI.ExportOptions
+}
+
+func (MIDI) Name() string         { return "midi" }
+func (MIDI) Extensions() []string { return []string{".mid", ".midi", ".smf"} }
+func (MIDI) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("MThd"))
+}
+func (m MIDI) Import(r io.Reader) (*ScoreDeleste.Score, error) {
+	return ScoreMIDI.Import(r, m.ImportOptions)
+}
+func (m MIDI) Export(w io.Writer, score *ScoreDeleste.Score) error {
+	return ScoreMIDI.Export(w, score, m.ExportOptions)
+}
+
+// Osu は osu!mania ビートマップ形式です (書き出しのみ)
+type Osu struct{}
+
+func (Osu) Name() string         { return "osu" }
+func (Osu) Extensions() []string { return []string{".osu"} }
+func (Osu) Sniff(head []byte) bool {
+	return bytes.HasPrefix(bytes.TrimPrefix(head, []byte{0xEF, 0xBB, 0xBF}), []byte("osu file format"))
+}
+func (Osu) Export(w io.Writer, score *ScoreDeleste.Score) error {
+	return ScoreOsu.Export(w, score)
+}
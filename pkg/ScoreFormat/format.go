@@ -0,0 +1,163 @@
+package ScoreFormat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// SniffSize は形式の判定に渡す先頭部分の最大バイト数です
+const SniffSize = 4096
+
+// Format は譜面形式を表します
+// 読み込みに対応する形式は Importer を、書き出しに対応する形式は Exporter を実装します
+type Format interface {
+	Name() string         // 形式名 (例: "deleste")
+	Extensions() []string // 拡張子 (ドット付き、小文字)
+	Sniff(head []byte) bool
+}
+
+// Importer は譜面を読み込める形式です
+type Importer interface {
+	Format
+	Import(r io.Reader) (*ScoreDeleste.Score, error)
+}
+
+// Exporter は譜面を書き出せる形式です
+type Exporter interface {
+	Format
+	Export(w io.Writer, score *ScoreDeleste.Score) error
+}
+
+var (
+	mu      sync.RWMutex
+	formats []Format
+)
+
+// Register は形式を登録します
+// 同じ名前の形式が登録済みの場合は置き換えます
+func Register(format Format) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, f := range formats {
+		if f.Name() == format.Name() {
+			formats[i] = format
+			return
+		}
+	}
+	formats = append(formats, format)
+}
+
+// Formats は登録済みの形式を登録順に返します
+func Formats() []Format {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return append([]Format{}, formats...)
+}
+
+// Lookup は名前で形式を探します
+func Lookup(name string) (Format, bool) {
+	for _, f := range Formats() {
+		if f.Name() == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// ByExtension は拡張子で形式を探します
+func ByExtension(path string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range Formats() {
+		for _, e := range f.Extensions() {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Detect はファイルの先頭部分と拡張子から読み込みに使う形式を判定します
+// 内容が一致する形式を優先し、複数一致した場合は拡張子も一致するものを選びます
+// 内容で判定できない場合は拡張子で判定します
+func Detect(path string, head []byte) (Importer, error) {
+	var sniffed []Importer
+	for _, f := range Formats() {
+		if importer, ok := f.(Importer); ok && f.Sniff(head) {
+			sniffed = append(sniffed, importer)
+		}
+	}
+
+	if byExt, ok := ByExtension(path); ok {
+		if importer, ok := byExt.(Importer); ok {
+			for _, s := range sniffed {
+				if s.Name() == importer.Name() {
+					return importer, nil
+				}
+			}
+			if len(sniffed) == 0 {
+				return importer, nil
+			}
+		}
+	}
+	if len(sniffed) > 0 {
+		return sniffed[0], nil
+	}
+	return nil, fmt.Errorf("unknown score format: %s", path)
+}
+
+// Open は譜面ファイルの形式を判定して読み込みます
+func Open(path string) (*ScoreDeleste.Score, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, SniffSize)
+	head, err := reader.Peek(SniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	importer, err := Detect(path, head)
+	if err != nil {
+		return nil, err
+	}
+	score, err := importer.Import(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", importer.Name(), err)
+	}
+	return score, nil
+}
+
+// Save は拡張子から形式を判定して譜面を書き出します
+func Save(path string, score *ScoreDeleste.Score) error {
+	format, ok := ByExtension(path)
+	if !ok {
+		return fmt.Errorf("unknown score format: %s", path)
+	}
+	exporter, ok := format.(Exporter)
+	if !ok {
+		return fmt.Errorf("%s: export is not supported", format.Name())
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := exporter.Export(file, score); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,62 @@
+package ScoreFormat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+const delesteChart = "\xEF\xBB\xBF#Title test\n#BPM 120\n#0,000:2020:1:1\n"
+
+const susChart = "#TITLE \"test\"\n#BPM01: 120\n#00008: 01\n#00010: 1400\n"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		head string
+		want string
+	}{
+		{"deleste by content", "chart.dat", delesteChart, "deleste"},
+		{"sus by content", "chart.txt", susChart, "sus"},
+		{"midi by content", "chart", "MThd\x00\x00\x00\x06", "midi"},
+		{"extension when content is unknown", "chart.sus", "", "sus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importer, err := Detect(tt.path, []byte(tt.head))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, importer.Name())
+		})
+	}
+
+	t.Run("export only format is not detected", func(t *testing.T) {
+		_, err := Detect("chart.osu", []byte("osu file format v14\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestOpenSave(t *testing.T) {
+	dir := t.TempDir()
+
+	// 拡張子と内容が食い違っていても内容で判定する
+	susPath := filepath.Join(dir, "chart.txt")
+	assert.NoError(t, os.WriteFile(susPath, []byte(susChart), 0o644))
+	score, err := Open(susPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", score.Header.Title)
+	assert.Equal(t, []ScoreDeleste.NoteType{ScoreDeleste.Tap}, score.Notes[0].Note)
+
+	midiPath := filepath.Join(dir, "chart.mid")
+	assert.NoError(t, Save(midiPath, score))
+	reopened, err := Open(midiPath)
+	assert.NoError(t, err)
+	assert.Equal(t, score.Notes, reopened.Notes)
+
+	assert.NoError(t, Save(filepath.Join(dir, "chart.osu"), score))
+	assert.Error(t, Save(filepath.Join(dir, "chart.unknown"), score))
+}
@@ -0,0 +1,249 @@
+package ScoreMIDI
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// TrackMode はエクスポート時のトラックの分け方です
+type TrackMode int
+
+const (
+	PerHand TrackMode = iota + 1 // 左手・右手の2トラック (チャンネルの偶奇で分ける)
+	PerLane                      // レーン 1-5 の5トラック
+)
+
+// DefaultBasePitch はレーン1に対応するノート番号 (C4) です
+const DefaultBasePitch = 60
+
+// DefaultResolution は4分音符あたりの tick 数です
+const DefaultResolution = 480
+
+// レーン数
+const laneCount = 5
+
+// ExportOptions は MIDI 書き出しの設定です
+type ExportOptions struct {
+	TrackMode  TrackMode // トラックの分け方 (0 の場合は PerHand)
+	BasePitch  int       // レーン1に対応するノート番号 (0 の場合は DefaultBasePitch)
+	Resolution int       // 4分音符あたりの tick 数 (0 の場合は DefaultResolution)
+}
+
+// ImportOptions は MIDI 読み込みの設定です
+type ImportOptions struct {
+	BasePitch int // レーン1に対応するノート番号、BasePitch から BasePitch+4 をレーン 1-5 として読む (0 の場合は DefaultBasePitch)
+}
+
+func (o ExportOptions) withDefaults() ExportOptions {
+	if o.TrackMode == 0 {
+		o.TrackMode = PerHand
+	}
+	if o.BasePitch == 0 {
+		o.BasePitch = DefaultBasePitch
+	}
+	if o.Resolution == 0 {
+		o.Resolution = DefaultResolution
+	}
+	return o
+}
+
+func (o ImportOptions) withDefaults() ImportOptions {
+	if o.BasePitch == 0 {
+		o.BasePitch = DefaultBasePitch
+	}
+	return o
+}
+
+// ベロシティとノートタイプの対応
+// 書き出しでは代表値を使い、読み込みでは範囲で判定する
+// ピアノロールで既定のベロシティ (64 以上) のまま置いたノーツはタップになる
+var velocityOf = map[ScoreDeleste.NoteType]byte{
+	ScoreDeleste.LeftFlick:  8,
+	ScoreDeleste.RightFlick: 24,
+	ScoreDeleste.LongStart:  40,
+	ScoreDeleste.Slide:      56,
+	ScoreDeleste.Tap:        100,
+}
+
+func noteTypeOf(velocity byte) ScoreDeleste.NoteType {
+	switch {
+	case velocity < 16:
+		return ScoreDeleste.LeftFlick
+	case velocity < 32:
+		return ScoreDeleste.RightFlick
+	case velocity < 48:
+		return ScoreDeleste.LongStart
+	case velocity < 64:
+		return ScoreDeleste.Slide
+	default:
+		return ScoreDeleste.Tap
+	}
+}
+
+// ExportFile は譜面を MIDI ファイルとして書き出します
+func ExportFile(filepath string, score *ScoreDeleste.Score, opts ExportOptions) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	if err := Export(file, score, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Export は譜面をフォーマット1の Standard MIDI File として書き出します
+// 1トラック目はテンポマップ、2トラック目以降は手またはレーンごとのノーツです
+// ノート番号がレーン、ベロシティがノートタイプ、MIDI チャンネルが譜面のチャンネル (16 の剰余) を表します
+func Export(w io.Writer, score *ScoreDeleste.Score, opts ExportOptions) error {
+	opts = opts.withDefaults()
+	ticksPerMeasure := opts.Resolution * 4
+
+	conductor := &smfTrack{}
+	if score.Header.Title != "" {
+		conductor.addMeta(0, metaTrackName, []byte(score.Header.Title))
+	}
+	conductor.addMeta(0, metaTimeSig, []byte{4, 2, 24, 8})
+	for _, change := range score.TempoMap() {
+		if change.BPM <= 0 {
+			continue
+		}
+		tick := ScoreDeleste.TickAt(change.Measure, change.Beat, change.BeatSet, ticksPerMeasure)
+		usPerQuarter := int(math.Round(60000000.0 / change.BPM))
+		conductor.addMeta(tick, metaTempo, []byte{byte(usPerQuarter >> 16), byte(usPerQuarter >> 8), byte(usPerQuarter)})
+	}
+
+	var names []string
+	switch opts.TrackMode {
+	case PerHand:
+		names = []string{"Left", "Right"}
+	case PerLane:
+		for lane := 1; lane <= laneCount; lane++ {
+			names = append(names, fmt.Sprintf("Lane%d", lane))
+		}
+	default:
+		return fmt.Errorf("unknown track mode: %d", opts.TrackMode)
+	}
+	tracks := make([]*smfTrack, len(names))
+	for i, name := range names {
+		tracks[i] = &smfTrack{}
+		tracks[i].addMeta(0, metaTrackName, []byte(name))
+	}
+
+	notes := score.TickNotes(ticksPerMeasure)
+	length := opts.Resolution / 8
+	for i, note := range notes {
+		if note.TargetPos < 1 || note.TargetPos > laneCount {
+			return fmt.Errorf("target position out of range at tick %d: %d", note.Tick, note.TargetPos)
+		}
+		channel := byte(note.Channel % 16)
+		pitch := byte(opts.BasePitch + note.TargetPos - 1)
+
+		// 同じチャンネル・レーンの次のノーツと重ならない長さにする
+		end := note.Tick + length
+		for _, next := range notes[i+1:] {
+			if next.Tick >= end {
+				break
+			}
+			if next.Channel%16 == note.Channel%16 && next.TargetPos == note.TargetPos && next.Tick > note.Tick {
+				end = next.Tick
+				break
+			}
+		}
+
+		var track *smfTrack
+		if opts.TrackMode == PerHand {
+			track = tracks[note.Channel%2]
+		} else {
+			track = tracks[note.TargetPos-1]
+		}
+		track.add(note.Tick, 0x90|channel, pitch, velocityOf[note.Note])
+		track.add(end, 0x80|channel, pitch, 0)
+	}
+
+	return writeSMF(w, opts.Resolution, append([]*smfTrack{conductor}, tracks...))
+}
+
+// ImportFile は MIDI ファイルを読み込み譜面に変換します
+func ImportFile(filepath string, opts ImportOptions) (*ScoreDeleste.Score, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Import(file, opts)
+}
+
+// Import は Standard MIDI File を読み込み譜面に変換します
+// BasePitch から5音をレーン 1-5 とし、範囲外のノート番号は無視します
+// 全トラックのノートオンをまとめ、MIDI チャンネルを譜面のチャンネルとして扱います
+// 同じチャンネル・同じ tick の和音は、2つ目以降を 16 ずつ離したチャンネル (書き出すと同じ MIDI チャンネル) に振り分けます
+func Import(r io.Reader, opts ImportOptions) (*ScoreDeleste.Score, error) {
+	opts = opts.withDefaults()
+
+	division, tracks, err := readSMF(r)
+	if err != nil {
+		return nil, err
+	}
+	ticksPerMeasure := division * 4
+
+	score := &ScoreDeleste.Score{}
+	notes := []ScoreDeleste.TickNote{}
+	used := map[[2]int]bool{} // [channel, tick]
+	for i, track := range tracks {
+		for _, event := range track.events {
+			data := event.data
+			switch {
+			case data[0] == 0xFF && data[1] == metaTempo && len(data) == 5:
+				usPerQuarter := int(data[2])<<16 | int(data[3])<<8 | int(data[4])
+				if usPerQuarter == 0 {
+					continue
+				}
+				bpm := math.Round(60000000.0/float64(usPerQuarter)*1000) / 1000
+				if event.tick == 0 {
+					score.Header.BPM = bpm
+					continue
+				}
+				measure, beat, beatSet := ScoreDeleste.PositionAt(event.tick, ticksPerMeasure)
+				score.BPMChanges = append(score.BPMChanges, ScoreDeleste.BPMChange{
+					Measure: measure,
+					Beat:    beat,
+					BeatSet: beatSet,
+					BPM:     bpm,
+				})
+			case data[0] == 0xFF && data[1] == metaTrackName && i == 0:
+				score.Header.Title = string(data[2:])
+			case data[0]&0xF0 == 0x90 && data[2] > 0:
+				lane := int(data[1]) - opts.BasePitch + 1
+				if lane < 1 || lane > laneCount {
+					continue
+				}
+				channel := int(data[0] & 0x0F)
+				for used[[2]int{channel, event.tick}] {
+					channel += 16
+				}
+				used[[2]int{channel, event.tick}] = true
+				notes = append(notes, ScoreDeleste.TickNote{
+					Channel:   channel,
+					Tick:      event.tick,
+					Note:      noteTypeOf(data[2]),
+					StartPos:  lane,
+					TargetPos: lane,
+				})
+			}
+		}
+	}
+	if score.Header.BPM == 0 {
+		// テンポ指定が無い SMF の既定値
+		score.Header.BPM = 120
+	}
+
+	score.Notes = ScoreDeleste.BuildNotes(notes, ticksPerMeasure)
+	return score, nil
+}
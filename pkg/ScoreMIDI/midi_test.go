@@ -0,0 +1,140 @@
+package ScoreMIDI
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+func testScore() *ScoreDeleste.Score {
+	return &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{Title: "test", BPM: 150},
+		Notes: []ScoreDeleste.Note{
+			{
+				Channel:   0,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap, ScoreDeleste.LongStart, ScoreDeleste.None, ScoreDeleste.Tap},
+				StartPos:  []int{1, 2, 2},
+				TargetPos: []int{1, 2, 2},
+			},
+			{
+				Channel:   1,
+				Measure:   1,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Slide, ScoreDeleste.Slide, ScoreDeleste.RightFlick},
+				StartPos:  []int{4, 5, 5},
+				TargetPos: []int{4, 5, 5},
+			},
+		},
+		BPMChanges: []ScoreDeleste.BPMChange{
+			{Measure: 1, Beat: 1, BeatSet: 2, BPM: 200},
+		},
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	for _, mode := range []TrackMode{PerHand, PerLane} {
+		score := testScore()
+
+		buf := &bytes.Buffer{}
+		err := Export(buf, score, ExportOptions{TrackMode: mode})
+		assert.NoError(t, err)
+
+		imported, err := Import(buf, ImportOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, score.Header.Title, imported.Header.Title)
+		assert.Equal(t, score.Header.BPM, imported.Header.BPM)
+		assert.Equal(t, score.BPMChanges, imported.BPMChanges)
+		assert.Equal(t, score.Notes, imported.Notes)
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Run("pitch range selects lanes", func(t *testing.T) {
+		score := testScore()
+
+		buf := &bytes.Buffer{}
+		err := Export(buf, score, ExportOptions{BasePitch: 48})
+		assert.NoError(t, err)
+
+		// 元のレーン 3-5 (ノート番号 50-52) だけが範囲に入り、レーン 1-3 として読まれる
+		imported, err := Import(buf, ImportOptions{BasePitch: 50})
+		assert.NoError(t, err)
+		assert.Equal(t, []ScoreDeleste.Note{
+			{
+				Channel:   1,
+				Measure:   1,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Slide, ScoreDeleste.Slide, ScoreDeleste.RightFlick},
+				StartPos:  []int{2, 3, 3},
+				TargetPos: []int{2, 3, 3},
+			},
+		}, imported.Notes)
+	})
+
+	t.Run("chord on one channel", func(t *testing.T) {
+		// ピアノロールで1つのチャンネルに置いた和音
+		track := &smfTrack{}
+		for _, pitch := range []byte{60, 62, 64} {
+			track.add(0, 0x90, pitch, 100)
+			track.add(60, 0x80, pitch, 0)
+		}
+		track.add(480, 0x90, 61, 100)
+		track.add(540, 0x80, 61, 0)
+
+		buf := &bytes.Buffer{}
+		err := writeSMF(buf, DefaultResolution, []*smfTrack{{}, track})
+		assert.NoError(t, err)
+
+		imported, err := Import(buf, ImportOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []ScoreDeleste.Note{
+			{
+				Channel:   0,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap, ScoreDeleste.Tap, ScoreDeleste.None, ScoreDeleste.None},
+				StartPos:  []int{1, 2},
+				TargetPos: []int{1, 2},
+			},
+			{
+				Channel:   16,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap},
+				StartPos:  []int{3},
+				TargetPos: []int{3},
+			},
+			{
+				Channel:   32,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap},
+				StartPos:  []int{5},
+				TargetPos: []int{5},
+			},
+		}, imported.Notes)
+	})
+
+	t.Run("velocity ranges", func(t *testing.T) {
+		assert.Equal(t, ScoreDeleste.LeftFlick, noteTypeOf(1))
+		assert.Equal(t, ScoreDeleste.RightFlick, noteTypeOf(16))
+		assert.Equal(t, ScoreDeleste.LongStart, noteTypeOf(47))
+		assert.Equal(t, ScoreDeleste.Slide, noteTypeOf(63))
+		assert.Equal(t, ScoreDeleste.Tap, noteTypeOf(64))
+		assert.Equal(t, ScoreDeleste.Tap, noteTypeOf(127))
+	})
+
+	t.Run("not a midi file", func(t *testing.T) {
+		_, err := Import(bytes.NewReader([]byte("#Title test\n")), ImportOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("zero time division", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, Export(buf, testScore(), ExportOptions{}))
+		data := buf.Bytes()
+		data[12], data[13] = 0, 0 // MThd の分解能
+
+		_, err := Import(bytes.NewReader(data), ImportOptions{})
+		assert.ErrorContains(t, err, "time division")
+	})
+}
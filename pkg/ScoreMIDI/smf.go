@@ -0,0 +1,231 @@
+package ScoreMIDI
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Standard MIDI File の読み書きに必要な最小限の実装です
+
+const (
+	metaTrackName = 0x03
+	metaEndTrack  = 0x2F
+	metaTempo     = 0x51
+	metaTimeSig   = 0x58
+)
+
+type smfEvent struct {
+	tick int
+	data []byte // ステータスバイトを含むメッセージ本体 (メタイベントは 0xFF, 種別, データ の順で長さを含まない)
+}
+
+type smfTrack struct {
+	events []smfEvent
+}
+
+func (t *smfTrack) add(tick int, data ...byte) {
+	t.events = append(t.events, smfEvent{tick: tick, data: data})
+}
+
+func (t *smfTrack) addMeta(tick int, metaType byte, payload []byte) {
+	t.add(tick, append([]byte{0xFF, metaType}, payload...)...)
+}
+
+// writeSMF はフォーマット1の SMF を書き出します
+func writeSMF(w io.Writer, division int, tracks []*smfTrack) error {
+	bw := bufio.NewWriter(w)
+
+	header := make([]byte, 14)
+	copy(header, "MThd")
+	binary.BigEndian.PutUint32(header[4:], 6)
+	binary.BigEndian.PutUint16(header[8:], 1)
+	binary.BigEndian.PutUint16(header[10:], uint16(len(tracks)))
+	binary.BigEndian.PutUint16(header[12:], uint16(division))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	for _, track := range tracks {
+		// 同じ tick ではノートオフをノートオンより先に置く
+		sort.SliceStable(track.events, func(i, j int) bool {
+			if track.events[i].tick != track.events[j].tick {
+				return track.events[i].tick < track.events[j].tick
+			}
+			return isNoteOff(track.events[i]) && !isNoteOff(track.events[j])
+		})
+
+		body := []byte{}
+		last := 0
+		for _, event := range track.events {
+			body = append(body, encodeVLQ(event.tick-last)...)
+			if event.data[0] == 0xFF {
+				body = append(body, event.data[:2]...)
+				body = append(body, encodeVLQ(len(event.data)-2)...)
+				body = append(body, event.data[2:]...)
+			} else {
+				body = append(body, event.data...)
+			}
+			last = event.tick
+		}
+		body = append(body, 0x00, 0xFF, metaEndTrack, 0x00)
+
+		chunk := make([]byte, 8)
+		copy(chunk, "MTrk")
+		binary.BigEndian.PutUint32(chunk[4:], uint32(len(body)))
+		if _, err := bw.Write(chunk); err != nil {
+			return err
+		}
+		if _, err := bw.Write(body); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readSMF は SMF を読み込み、分解能とトラックごとのイベントを返します
+// ランニングステータスは展開済みの状態で返します
+func readSMF(r io.Reader) (int, []*smfTrack, error) {
+	br := bufio.NewReader(r)
+
+	chunkType, body, err := readChunk(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	if chunkType != "MThd" || len(body) < 6 {
+		return 0, nil, errors.New("not a standard midi file")
+	}
+	trackCount := int(binary.BigEndian.Uint16(body[2:]))
+	division := int(binary.BigEndian.Uint16(body[4:]))
+	if division&0x8000 != 0 {
+		return 0, nil, errors.New("smpte time division is not supported")
+	}
+	if division == 0 {
+		return 0, nil, errors.New("time division must not be 0")
+	}
+
+	tracks := []*smfTrack{}
+	for len(tracks) < trackCount {
+		chunkType, body, err := readChunk(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if chunkType != "MTrk" {
+			continue
+		}
+		track, err := parseTrack(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("track %d: %w", len(tracks), err)
+		}
+		tracks = append(tracks, track)
+	}
+	return division, tracks, nil
+}
+
+func readChunk(r io.Reader) (string, []byte, error) {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return "", nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(head[4:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", nil, err
+	}
+	return string(head[:4]), body, nil
+}
+
+func parseTrack(body []byte) (*smfTrack, error) {
+	track := &smfTrack{}
+	pos := 0
+	tick := 0
+	var status byte
+
+	for pos < len(body) {
+		delta, n, err := decodeVLQ(body[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		tick += delta
+		if pos >= len(body) {
+			return nil, errors.New("unexpected end of track")
+		}
+
+		if body[pos]&0x80 != 0 {
+			status = body[pos]
+			pos++
+		} else if status == 0 {
+			return nil, errors.New("running status without status byte")
+		}
+
+		switch {
+		case status == 0xFF:
+			if pos >= len(body) {
+				return nil, errors.New("unexpected end of meta event")
+			}
+			metaType := body[pos]
+			length, n, err := decodeVLQ(body[pos+1:])
+			if err != nil {
+				return nil, err
+			}
+			start := pos + 1 + n
+			if start+length > len(body) {
+				return nil, errors.New("meta event overruns track")
+			}
+			track.add(tick, append([]byte{0xFF, metaType}, body[start:start+length]...)...)
+			pos = start + length
+			status = 0
+			if metaType == metaEndTrack {
+				return track, nil
+			}
+		case status == 0xF0 || status == 0xF7:
+			length, n, err := decodeVLQ(body[pos:])
+			if err != nil {
+				return nil, err
+			}
+			pos += n + length
+			status = 0
+		default:
+			size := 2
+			if status&0xF0 == 0xC0 || status&0xF0 == 0xD0 {
+				size = 1
+			}
+			if pos+size > len(body) {
+				return nil, errors.New("channel event overruns track")
+			}
+			track.add(tick, append([]byte{status}, body[pos:pos+size]...)...)
+			pos += size
+		}
+	}
+	return track, nil
+}
+
+func isNoteOff(event smfEvent) bool {
+	status := event.data[0] & 0xF0
+	return status == 0x80 || (status == 0x90 && event.data[2] == 0)
+}
+
+func encodeVLQ(value int) []byte {
+	buf := []byte{byte(value & 0x7F)}
+	for value >>= 7; value > 0; value >>= 7 {
+		buf = append([]byte{byte(value&0x7F) | 0x80}, buf...)
+	}
+	return buf
+}
+
+func decodeVLQ(data []byte) (int, int, error) {
+	value := 0
+	for i := 0; i < len(data) && i < 4; i++ {
+		value = value<<7 | int(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("invalid variable length quantity")
+}
@@ -0,0 +1,213 @@
+package ScoreOsu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// キー数 (レーン数)
+const keyCount = 5
+
+// osu! のプレイフィールドの幅
+const playfieldWidth = 512
+
+var difficultyNames = map[ScoreDeleste.Difficulty]string{
+	ScoreDeleste.Debug:      "Debug",
+	ScoreDeleste.Regular:    "Regular",
+	ScoreDeleste.Pro:        "Pro",
+	ScoreDeleste.Master:     "Master",
+	ScoreDeleste.MasterPlus: "Master+",
+}
+
+type hitObject struct {
+	column  int
+	timeMs  int
+	hold    bool
+	endMs   int // ホールドの終点
+	channel int
+}
+
+// ExportFile は譜面を osu!mania の .osu ファイルとして書き出します
+func ExportFile(filepath string, score *ScoreDeleste.Score) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	if err := Export(file, score); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Export は譜面を 5K の osu!mania ビートマップとして書き出します
+// ロングノーツは始点から同じチャンネルの次のノーツまでのホールドノーツに、
+// フリックとスライドの各点はタップに変換します
+// 時刻は ScoreDeleste.Score.AudioTimeMs に従い音源上の時刻で書き出します
+// 音源と背景画像は .osu ファイルと同じフォルダに置く前提で、ファイル名だけを書き出します
+func Export(w io.Writer, score *ScoreDeleste.Score) error {
+	objects, err := hitObjects(score)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "osu file format v14")
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "[General]")
+	fmt.Fprintf(bw, "AudioFilename: %s\n", fileName(score.Header.Song))
+	fmt.Fprintln(bw, "AudioLeadIn: 0")
+	fmt.Fprintln(bw, "PreviewTime: -1")
+	fmt.Fprintln(bw, "Countdown: 0")
+	fmt.Fprintln(bw, "SampleSet: Normal")
+	fmt.Fprintln(bw, "StackLeniency: 0.7")
+	fmt.Fprintln(bw, "Mode: 3")
+	fmt.Fprintln(bw, "LetterboxInBreaks: 0")
+	fmt.Fprintln(bw, "SpecialStyle: 0")
+	fmt.Fprintln(bw, "WidescreenStoryboard: 0")
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "[Metadata]")
+	fmt.Fprintf(bw, "Title:%s\n", score.Header.Title)
+	fmt.Fprintf(bw, "TitleUnicode:%s\n", score.Header.Title)
+	fmt.Fprintf(bw, "Artist:%s\n", score.Header.Composer)
+	fmt.Fprintf(bw, "ArtistUnicode:%s\n", score.Header.Composer)
+	fmt.Fprintln(bw, "Creator:")
+	fmt.Fprintf(bw, "Version:%s\n", versionName(score.Header))
+	fmt.Fprintln(bw, "Source:")
+	fmt.Fprintln(bw, "Tags:")
+	fmt.Fprintln(bw, "BeatmapID:0")
+	fmt.Fprintln(bw, "BeatmapSetID:-1")
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "[Difficulty]")
+	fmt.Fprintln(bw, "HPDrainRate:8")
+	fmt.Fprintf(bw, "CircleSize:%d\n", keyCount)
+	fmt.Fprintln(bw, "OverallDifficulty:8")
+	fmt.Fprintln(bw, "ApproachRate:5")
+	fmt.Fprintln(bw, "SliderMultiplier:1.4")
+	fmt.Fprintln(bw, "SliderTickRate:1")
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "[Events]")
+	fmt.Fprintln(bw, "//Background and Video events")
+	if background := fileName(score.Header.Background); background != "" {
+		fmt.Fprintf(bw, "0,0,\"%s\",0,0\n", background)
+	}
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "[TimingPoints]")
+	for _, change := range score.TempoMap() {
+		if change.BPM <= 0 {
+			continue
+		}
+		timeMs := int(math.Round(score.AudioTimeMs(change.Measure, change.Beat, change.BeatSet)))
+		beatLength := strconv.FormatFloat(60000.0/change.BPM, 'f', -1, 64)
+		fmt.Fprintf(bw, "%d,%s,4,1,0,100,1,0\n", timeMs, beatLength)
+	}
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "[HitObjects]")
+	for _, object := range objects {
+		x := (2*object.column + 1) * playfieldWidth / (2 * keyCount)
+		if object.hold {
+			fmt.Fprintf(bw, "%d,192,%d,128,0,%d:0:0:0:0:\n", x, object.timeMs, object.endMs)
+		} else {
+			fmt.Fprintf(bw, "%d,192,%d,1,0,0:0:0:0:\n", x, object.timeMs)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func versionName(header ScoreDeleste.Header) string {
+	name, ok := difficultyNames[header.Difficulty]
+	if !ok {
+		name = "Deleste"
+	}
+	if header.Level > 0 {
+		name = fmt.Sprintf("%s Lv.%d", name, header.Level)
+	}
+	return name
+}
+
+// fileName はパスからファイル名だけを取り出します (区切りは / と \ のどちらでも構いません)
+// ファイル名が無い場合は空文字列を返します
+func fileName(filepath string) string {
+	name := path.Base(strings.ReplaceAll(filepath, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// hitObjects は譜面のノーツを時刻順のヒットオブジェクトに変換します
+func hitObjects(score *ScoreDeleste.Score) ([]hitObject, error) {
+	notes := []hitObject{}
+	types := []ScoreDeleste.NoteType{}
+	for _, note := range score.Notes {
+		count := 0
+		for beat, noteType := range note.Note {
+			if noteType == ScoreDeleste.None {
+				continue
+			}
+			if count >= len(note.TargetPos) {
+				return nil, fmt.Errorf("missing target position at channel %d measure %d", note.Channel, note.Measure)
+			}
+			pos := note.TargetPos[count]
+			if pos < 1 || pos > keyCount {
+				return nil, fmt.Errorf("target position out of range at channel %d measure %d: %d", note.Channel, note.Measure, pos)
+			}
+			notes = append(notes, hitObject{
+				column:  pos - 1,
+				timeMs:  int(math.Round(score.AudioTimeMs(note.Measure, beat, len(note.Note)))),
+				channel: note.Channel,
+			})
+			types = append(types, noteType)
+			count++
+		}
+	}
+
+	order := make([]int, len(notes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return notes[order[i]].timeMs < notes[order[j]].timeMs
+	})
+
+	// ロングノーツの始点を同じチャンネルの次のノーツと組にする
+	objects := []hitObject{}
+	holding := map[int]int{} // チャンネル -> objects 内の始点の位置
+	for _, i := range order {
+		note := notes[i]
+		if start, ok := holding[note.channel]; ok {
+			objects[start].hold = true
+			objects[start].endMs = note.timeMs
+			delete(holding, note.channel)
+			continue
+		}
+		if types[i] == ScoreDeleste.LongStart {
+			holding[note.channel] = len(objects)
+		}
+		objects = append(objects, note)
+	}
+	if len(holding) > 0 {
+		first := len(objects)
+		for _, start := range holding {
+			first = min(first, start)
+		}
+		return nil, fmt.Errorf("long note without end at channel %d (%d ms)", objects[first].channel, objects[first].timeMs)
+	}
+	return objects, nil
+}
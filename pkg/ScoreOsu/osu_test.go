@@ -0,0 +1,119 @@
+package ScoreOsu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+func TestExport(t *testing.T) {
+	score := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{
+			Title:      "test",
+			Song:       "music/song.wav",
+			BPM:        120,
+			Offset:     1000,
+			SongOffset: 200,
+			Difficulty: ScoreDeleste.Master,
+			Level:      26,
+		},
+		Notes: []ScoreDeleste.Note{
+			{
+				Channel:   0,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap, ScoreDeleste.LongStart, ScoreDeleste.None, ScoreDeleste.RightFlick},
+				StartPos:  []int{1, 3, 3},
+				TargetPos: []int{1, 3, 3},
+			},
+			{
+				Channel:   1,
+				Measure:   1,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap},
+				StartPos:  []int{5},
+				TargetPos: []int{5},
+			},
+		},
+		BPMChanges: []ScoreDeleste.BPMChange{
+			{Measure: 1, Beat: 0, BeatSet: 1, BPM: 240},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := Export(buf, score)
+	assert.NoError(t, err)
+
+	osu := buf.String()
+	assert.Contains(t, osu, "AudioFilename: song.wav\n")
+	assert.NotContains(t, osu, "music/")
+	assert.Contains(t, osu, "Version:Master Lv.26\n")
+	assert.Contains(t, osu, "CircleSize:5\n")
+
+	timing := section(osu, "[TimingPoints]")
+	assert.Equal(t, []string{
+		"800,500,4,1,0,100,1,0",
+		"2800,250,4,1,0,100,1,0",
+	}, timing)
+
+	objects := section(osu, "[HitObjects]")
+	assert.Equal(t, []string{
+		"51,192,800,1,0,0:0:0:0:",
+		"256,192,1300,128,0,2300:0:0:0:0:",
+		"460,192,2800,1,0,0:0:0:0:",
+	}, objects)
+}
+
+func TestExportUnterminatedLongNote(t *testing.T) {
+	score := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{BPM: 120},
+		Notes: []ScoreDeleste.Note{
+			{
+				Channel:   0,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.LongStart},
+				StartPos:  []int{2},
+				TargetPos: []int{2},
+			},
+		},
+	}
+	err := Export(&bytes.Buffer{}, score)
+	assert.Error(t, err)
+}
+
+func TestExportFileNames(t *testing.T) {
+	cases := []struct {
+		song, background string
+		audio            string
+		events           []string
+	}{
+		{"music/song.wav", "images/bg.png", "AudioFilename: song.wav", []string{"//Background and Video events", `0,0,"bg.png",0,0`}},
+		{`S:\music\song.wav`, `images\bg.png`, "AudioFilename: song.wav", []string{"//Background and Video events", `0,0,"bg.png",0,0`}},
+		{"", "", "AudioFilename: ", []string{"//Background and Video events"}},
+	}
+	for _, c := range cases {
+		score := &ScoreDeleste.Score{Header: ScoreDeleste.Header{BPM: 120, Song: c.song, Background: c.background}}
+		buf := &bytes.Buffer{}
+		assert.NoError(t, Export(buf, score))
+		assert.Contains(t, section(buf.String(), "[General]"), c.audio, c.song)
+		assert.Equal(t, c.events, section(buf.String(), "[Events]"), c.background)
+	}
+}
+
+func section(osu, name string) []string {
+	lines := []string{}
+	inSection := false
+	for _, line := range strings.Split(osu, "\n") {
+		switch {
+		case line == name:
+			inSection = true
+		case inSection && line == "":
+			return lines
+		case inSection:
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
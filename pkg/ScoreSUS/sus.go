@@ -0,0 +1,472 @@
+package ScoreSUS
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// DefaultTicksPerBeat は #REQUEST "ticks_per_beat" が無い場合の1拍あたりの tick 数です
+const DefaultTicksPerBeat = 480
+
+// SUS のレーン数 (0-f)
+const susLaneCount = 16
+
+// 1小節あたりの拍数 (4拍子のみ対応)
+const beatsPerMeasure = 4
+
+type susNote struct {
+	tick  int  // 譜面先頭からの tick
+	lane  int  // 左端レーン (0-15)
+	width int  // 幅 (1-16)
+	kind  byte // チャンネル種別 ('1': ショート, '2': ホールド, '3': スライド, '5': 方向)
+	typ   int  // ノーツ種別
+	id    byte // ホールド・スライドの識別子
+}
+
+type susParser struct {
+	ticksPerBeat int
+	bpmDefs      map[string]float64
+	bpmEvents    []bpmEvent
+	notes        []susNote
+}
+
+type bpmEvent struct {
+	tick int
+	id   string
+}
+
+// ParseScore は SUS ファイルを読み込み ScoreDeleste.Score に変換します
+func ParseScore(filepath string) (*ScoreDeleste.Score, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// Parse は SUS 形式の譜面を読み込み ScoreDeleste.Score に変換します
+// レーン (0-f, 幅付き) は中心位置で 1-5 の目標位置に縮約します
+// ホールドとスライドはそれぞれ専用のチャンネルに割り当て、スライドの中継点は Slide ノーツとして並べます
+func Parse(r io.Reader) (*ScoreDeleste.Score, error) {
+	p := &susParser{
+		ticksPerBeat: DefaultTicksPerBeat,
+		bpmDefs:      map[string]float64{},
+	}
+	score := &ScoreDeleste.Score{}
+
+	type dataLine struct {
+		measure int
+		key     string
+		data    string
+	}
+	lines := []dataLine{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "#")
+
+		if len(line) > 3 && isDigits(line[:3]) && strings.Contains(line, ":") {
+			// #mmmcx:data 形式はノーツ情報 (ticks_per_beat 確定後に解釈する)
+			parts := strings.SplitN(line, ":", 2)
+			measure, _ := strconv.Atoi(parts[0][:3])
+			lines = append(lines, dataLine{
+				measure: measure,
+				key:     parts[0][3:],
+				data:    strings.Join(strings.Fields(parts[1]), ""),
+			})
+			continue
+		}
+
+		if err := p.parseHeader(line, score); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		if err := p.parseData(line.measure, line.key, line.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.buildTempo(score); err != nil {
+		return nil, err
+	}
+	if err := p.buildNotes(score); err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+func (p *susParser) parseHeader(line string, score *ScoreDeleste.Score) error {
+	// #BPMxx: 120.0
+	if strings.HasPrefix(line, "BPM") && strings.Contains(line, ":") {
+		parts := strings.SplitN(line, ":", 2)
+		bpm, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return fmt.Errorf("invalid bpm definition: %s", line)
+		}
+		p.bpmDefs[strings.ToLower(strings.TrimPrefix(parts[0], "BPM"))] = bpm
+		return nil
+	}
+
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	value := strings.Trim(strings.TrimSpace(parts[1]), "\"")
+
+	switch strings.ToUpper(parts[0]) {
+	case "TITLE":
+		score.Header.Title = value
+	case "ARTIST":
+		score.Header.Composer = value
+	case "WAVE":
+		score.Header.Song = value
+	case "JACKET":
+		score.Header.Background = value
+	case "WAVEOFFSET":
+		// 秒単位で、譜面の 0 小節目が音源の何秒目にあたるかを表す
+		if offset, err := strconv.ParseFloat(value, 64); err == nil {
+			score.Header.Offset = int(math.Round(offset * 1000))
+		}
+	case "PLAYLEVEL":
+		if level, err := strconv.Atoi(strings.TrimRight(value, "+")); err == nil {
+			score.Header.Level = level
+		}
+	case "REQUEST":
+		fields := strings.Fields(value)
+		if len(fields) == 2 && fields[0] == "ticks_per_beat" {
+			ticks, err := strconv.Atoi(fields[1])
+			if err != nil || ticks <= 0 {
+				return fmt.Errorf("invalid ticks_per_beat: %s", fields[1])
+			}
+			p.ticksPerBeat = ticks
+		}
+	}
+	return nil
+}
+
+func (p *susParser) ticksPerMeasure() int {
+	return p.ticksPerBeat * beatsPerMeasure
+}
+
+func (p *susParser) parseData(measure int, key, data string) error {
+	if len(key) < 2 || len(data)%2 != 0 {
+		return fmt.Errorf("invalid data line: #%03d%s:%s", measure, key, data)
+	}
+
+	switch {
+	case key == "02":
+		// 小節長 (拍数)
+		if length, err := strconv.ParseFloat(data, 64); err != nil || length != beatsPerMeasure {
+			return fmt.Errorf("unsupported measure length at measure %d: %s", measure, data)
+		}
+		return nil
+	case key == "08":
+		// BPM 変化
+		count := len(data) / 2
+		for i := 0; i < count; i++ {
+			id := strings.ToLower(data[i*2 : i*2+2])
+			if id == "00" {
+				continue
+			}
+			p.bpmEvents = append(p.bpmEvents, bpmEvent{
+				tick: p.tickAt(measure, i, count),
+				id:   id,
+			})
+		}
+		return nil
+	}
+
+	kind := key[0]
+	switch kind {
+	case '1', '5':
+		if len(key) != 2 {
+			return nil
+		}
+	case '2', '3':
+		if len(key) != 3 {
+			return nil
+		}
+	default:
+		// ガイド等の未対応チャンネルは読み飛ばす
+		return nil
+	}
+
+	lane, err := strconv.ParseInt(key[1:2], 16, 0)
+	if err != nil {
+		return fmt.Errorf("invalid lane at measure %d: %s", measure, key)
+	}
+	var id byte
+	if len(key) == 3 {
+		id = key[2]
+	}
+
+	count := len(data) / 2
+	for i := 0; i < count; i++ {
+		typ, err := strconv.ParseInt(data[i*2:i*2+1], 36, 0)
+		if err != nil {
+			return fmt.Errorf("invalid note type at measure %d: %s", measure, data)
+		}
+		if typ == 0 {
+			continue
+		}
+		width, err := strconv.ParseInt(data[i*2+1:i*2+2], 36, 0)
+		if err != nil || width <= 0 {
+			return fmt.Errorf("invalid note width at measure %d: %s", measure, data)
+		}
+		p.notes = append(p.notes, susNote{
+			tick:  p.tickAt(measure, i, count),
+			lane:  int(lane),
+			width: int(width),
+			kind:  kind,
+			typ:   int(typ),
+			id:    id,
+		})
+	}
+	return nil
+}
+
+func (p *susParser) tickAt(measure, index, count int) int {
+	return measure*p.ticksPerMeasure() + index*p.ticksPerMeasure()/count
+}
+
+func (p *susParser) buildTempo(score *ScoreDeleste.Score) error {
+	sort.SliceStable(p.bpmEvents, func(i, j int) bool {
+		return p.bpmEvents[i].tick < p.bpmEvents[j].tick
+	})
+	for _, event := range p.bpmEvents {
+		bpm, ok := p.bpmDefs[event.id]
+		if !ok {
+			return fmt.Errorf("undefined bpm: %s", event.id)
+		}
+		if event.tick == 0 {
+			score.Header.BPM = bpm
+			continue
+		}
+		measure, beat, beatSet := ScoreDeleste.PositionAt(event.tick, p.ticksPerMeasure())
+		score.BPMChanges = append(score.BPMChanges, ScoreDeleste.BPMChange{
+			Measure: measure,
+			Beat:    beat,
+			BeatSet: beatSet,
+			BPM:     bpm,
+		})
+	}
+	if score.Header.BPM == 0 {
+		// 0 小節目に BPM 指定が無い場合は最初の定義を使う
+		if bpm, ok := p.bpmDefs["01"]; ok {
+			score.Header.BPM = bpm
+		}
+	}
+	return nil
+}
+
+// targetPos は SUS のレーン (左端と幅) を 1-5 の目標位置に変換します
+func targetPos(lane, width int) int {
+	center2 := lane*2 + width // 半レーン単位の中心位置 (0-32)
+	pos := center2*5/(susLaneCount*2) + 1
+	return min(max(pos, 1), 5)
+}
+
+type pointKey struct {
+	tick int
+	pos  int
+}
+
+func (p *susParser) buildNotes(score *ScoreDeleste.Score) error {
+	// 方向ノーツはショートノーツ・終点をフリックに変える
+	flicks := map[pointKey]ScoreDeleste.NoteType{}
+	for _, n := range p.notes {
+		if n.kind != '5' {
+			continue
+		}
+		switch n.typ {
+		case 3, 5:
+			flicks[pointKey{n.tick, targetPos(n.lane, n.width)}] = ScoreDeleste.LeftFlick
+		case 4, 6:
+			flicks[pointKey{n.tick, targetPos(n.lane, n.width)}] = ScoreDeleste.RightFlick
+		}
+	}
+
+	chains, err := p.buildChains(flicks)
+	if err != nil {
+		return err
+	}
+
+	// ホールド・スライドと重なるショートノーツは装飾なので除外する
+	chainPoints := map[pointKey]bool{}
+	for _, chain := range chains {
+		for _, n := range chain {
+			chainPoints[pointKey{n.Tick, n.TargetPos}] = true
+		}
+	}
+
+	notes := []ScoreDeleste.TickNote{}
+	used := map[[2]int]bool{} // [channel, tick]
+	maxChannel := -1
+	for _, n := range p.notes {
+		if n.kind != '1' {
+			continue
+		}
+		pos := targetPos(n.lane, n.width)
+		key := pointKey{n.tick, pos}
+		if chainPoints[key] {
+			continue
+		}
+
+		var noteType ScoreDeleste.NoteType
+		switch n.typ {
+		case 1, 2:
+			noteType = ScoreDeleste.Tap
+		case 3:
+			// 方向の無いフリックは外側に払う
+			if pos <= 3 {
+				noteType = ScoreDeleste.LeftFlick
+			} else {
+				noteType = ScoreDeleste.RightFlick
+			}
+		default:
+			// ダメージノーツ等は対象外
+			continue
+		}
+		if flick, ok := flicks[key]; ok {
+			noteType = flick
+		}
+
+		// 同時押しは同じ側の別チャンネルに振り分ける
+		channel := sideChannel(pos)
+		for used[[2]int{channel, n.tick}] {
+			channel += 2
+		}
+		used[[2]int{channel, n.tick}] = true
+		maxChannel = max(maxChannel, channel)
+		notes = append(notes, ScoreDeleste.TickNote{Channel: channel, Tick: n.tick, Note: noteType, StartPos: pos, TargetPos: pos})
+	}
+
+	// ホールド・スライドは1本ごとに専用のチャンネルを使う
+	next := maxChannel + 1
+	for _, chain := range chains {
+		channel := next
+		if channel%2 != sideChannel(chain[0].TargetPos) {
+			channel++
+		}
+		next = channel + 1
+		for _, n := range chain {
+			n.Channel = channel
+			notes = append(notes, n)
+		}
+	}
+
+	score.Notes = ScoreDeleste.BuildNotes(notes, p.ticksPerMeasure())
+	return nil
+}
+
+// buildChains はホールド・スライドを識別子ごとに連結し、始点から終点までのイベント列を返します
+func (p *susParser) buildChains(flicks map[pointKey]ScoreDeleste.NoteType) ([][]ScoreDeleste.TickNote, error) {
+	type chainKey struct {
+		kind byte
+		id   byte
+	}
+	grouped := map[chainKey][]susNote{}
+	keys := []chainKey{}
+	for _, n := range p.notes {
+		if n.kind != '2' && n.kind != '3' {
+			continue
+		}
+		key := chainKey{n.kind, n.id}
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+		}
+		grouped[key] = append(grouped[key], n)
+	}
+
+	chains := [][]ScoreDeleste.TickNote{}
+	for _, key := range keys {
+		notes := grouped[key]
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].tick < notes[j].tick
+		})
+
+		var current []ScoreDeleste.TickNote
+		for _, n := range notes {
+			pos := targetPos(n.lane, n.width)
+			switch n.typ {
+			case 1:
+				if current != nil {
+					return nil, fmt.Errorf("unterminated chain %c%c at tick %d", key.kind, key.id, n.tick)
+				}
+				noteType := ScoreDeleste.LongStart
+				if key.kind == '3' {
+					noteType = ScoreDeleste.Slide
+				}
+				current = []ScoreDeleste.TickNote{{Tick: n.tick, Note: noteType, StartPos: pos, TargetPos: pos}}
+			case 3, 5:
+				// 中継点 (不可視中継点も経路として通過する)
+				if current == nil {
+					return nil, fmt.Errorf("relay without start %c%c at tick %d", key.kind, key.id, n.tick)
+				}
+				if key.kind == '3' {
+					current = append(current, ScoreDeleste.TickNote{Tick: n.tick, Note: ScoreDeleste.Slide, StartPos: pos, TargetPos: pos})
+				}
+			case 2:
+				if current == nil {
+					return nil, fmt.Errorf("end without start %c%c at tick %d", key.kind, key.id, n.tick)
+				}
+				noteType := ScoreDeleste.Tap
+				if key.kind == '3' {
+					noteType = ScoreDeleste.Slide
+				}
+				if flick, ok := flicks[pointKey{n.tick, pos}]; ok {
+					noteType = flick
+				}
+				current = append(current, ScoreDeleste.TickNote{Tick: n.tick, Note: noteType, StartPos: pos, TargetPos: pos})
+				chains = append(chains, current)
+				current = nil
+			}
+			// 4 (曲線制御点) は経路の形状のみなので無視する
+		}
+		if current != nil {
+			return nil, fmt.Errorf("unterminated chain %c%c", key.kind, key.id)
+		}
+	}
+
+	sort.SliceStable(chains, func(i, j int) bool {
+		return chains[i][0].Tick < chains[j][0].Tick
+	})
+	return chains, nil
+}
+
+// sideChannel は目標位置から手の側に対応するチャンネルの偶奇を返します
+// 偶数チャンネルは左手、奇数チャンネルは右手として扱われます
+func sideChannel(pos int) int {
+	if pos <= 3 {
+		return 0
+	}
+	return 1
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
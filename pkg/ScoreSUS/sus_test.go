@@ -0,0 +1,105 @@
+package ScoreSUS
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("header and tempo", func(t *testing.T) {
+		sus := `#TITLE "test song"
+#ARTIST "someone"
+#WAVE "song.wav"
+#WAVEOFFSET 0.5
+#REQUEST "ticks_per_beat 192"
+#BPM01: 120
+#BPM02: 180
+#00008: 01
+#00108: 0002
+`
+		score, err := Parse(strings.NewReader(sus))
+		assert.NoError(t, err)
+		assert.Equal(t, "test song", score.Header.Title)
+		assert.Equal(t, "someone", score.Header.Composer)
+		assert.Equal(t, "song.wav", score.Header.Song)
+		assert.Equal(t, 500, score.Header.Offset)
+		assert.Equal(t, 120.0, score.Header.BPM)
+		assert.Equal(t, []ScoreDeleste.BPMChange{
+			{Measure: 1, Beat: 1, BeatSet: 2, BPM: 180},
+		}, score.BPMChanges)
+	})
+
+	t.Run("fractional wave offset", func(t *testing.T) {
+		// 2.01 * 1000 は浮動小数点では 2009.99... になる
+		score, err := Parse(strings.NewReader("#WAVEOFFSET 2.01\n#BPM01: 120\n#00008: 01\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, 2010, score.Header.Offset)
+	})
+
+	t.Run("taps and flicks", func(t *testing.T) {
+		sus := `#BPM01: 120
+#00008: 01
+#00010: 14001400
+#0001c: 00140000
+#0005c: 00340000
+`
+		score, err := Parse(strings.NewReader(sus))
+		assert.NoError(t, err)
+		assert.Equal(t, []ScoreDeleste.Note{
+			{
+				Channel:   0,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.Tap, ScoreDeleste.Tap},
+				StartPos:  []int{1, 1},
+				TargetPos: []int{1, 1},
+			},
+			{
+				Channel:   1,
+				Measure:   0,
+				Note:      []ScoreDeleste.NoteType{ScoreDeleste.None, ScoreDeleste.LeftFlick, ScoreDeleste.None, ScoreDeleste.None},
+				StartPos:  []int{5},
+				TargetPos: []int{5},
+			},
+		}, score.Notes)
+	})
+
+	t.Run("hold and slide with relay points", func(t *testing.T) {
+		sus := `#BPM01: 120
+#00008: 01
+#00020a: 14002400
+#00036b: 1400
+#00038b: 00000034
+#0013ab: 2400
+`
+		score, err := Parse(strings.NewReader(sus))
+		assert.NoError(t, err)
+
+		paths := score.SlidePaths()
+		assert.Len(t, paths, 1)
+		assert.Equal(t, []ScoreDeleste.SlidePoint{
+			{Measure: 0, Beat: 0, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 3},
+			{Measure: 0, Beat: 3, BeatSet: 4, Note: ScoreDeleste.Slide, TargetPos: 4},
+			{Measure: 1, Beat: 0, BeatSet: 1, Note: ScoreDeleste.Slide, TargetPos: 4},
+		}, paths[0].Points)
+
+		var hold *ScoreDeleste.Note
+		for i := range score.Notes {
+			if score.Notes[i].Note[0] == ScoreDeleste.LongStart {
+				hold = &score.Notes[i]
+			}
+		}
+		assert.NotNil(t, hold)
+		assert.Equal(t, []ScoreDeleste.NoteType{ScoreDeleste.LongStart, ScoreDeleste.Tap}, hold.Note)
+		assert.Equal(t, []int{1, 1}, hold.TargetPos)
+		assert.NotEqual(t, hold.Channel, paths[0].Channel)
+	})
+
+	t.Run("unsupported measure length", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("#00002: 3\n"))
+		assert.Error(t, err)
+	})
+}
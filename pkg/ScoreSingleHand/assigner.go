@@ -0,0 +1,263 @@
+package ScoreSingleHand
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// HandAssigner はノーツを左手と右手に振り分けます
+// ロングノーツとスライドは始点から終点まで同じ手に振り分ける必要があります
+type HandAssigner interface {
+	// Assign は1つ目に左手、2つ目に右手のノーツを返します
+	Assign(notes []Note) ([]Note, []Note, error)
+}
+
+// armAssigner は腕のソレノイド配置を考慮する振り分け方です
+// ConvertFromDelesteWithArms は左右の腕を設定したものを使います
+type armAssigner interface {
+	withArms(left, right CommandArm.Arm) HandAssigner
+}
+
+// DefaultHandAssigner は手の振り分け方の既定値です
+var DefaultHandAssigner HandAssigner = ChannelParity{}
+
+// 振り分け方の名前と生成関数 (CLI からの選択用)
+var handAssigners = map[string]func() HandAssigner{
+	"parity":     func() HandAssigner { return ChannelParity{} },
+	"parity-odd": func() HandAssigner { return ChannelParity{OddIsLeft: true} },
+	"zone":       func() HandAssigner { return LaneZone{} },
+	"nearest":    func() HandAssigner { return NearestFreeArm{} },
+	"optimal":    func() HandAssigner { return Optimal{} },
+}
+
+// HandAssignerNames は名前で選べる振り分け方の一覧を返します
+func HandAssignerNames() []string {
+	names := make([]string, 0, len(handAssigners))
+	for name := range handAssigners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewHandAssigner は名前から振り分け方を生成します
+func NewHandAssigner(name string) (HandAssigner, error) {
+	newAssigner, ok := handAssigners[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hand assigner: %s (available: %v)", name, HandAssignerNames())
+	}
+	return newAssigner(), nil
+}
+
+// ChannelParity はチャンネル番号の偶奇で手を決めます
+// 既定では偶数チャンネルを左手、奇数チャンネルを右手とし、OddIsLeft で反転します
+type ChannelParity struct {
+	OddIsLeft bool
+}
+
+func (a ChannelParity) Assign(notes []Note) ([]Note, []Note, error) {
+	result := make([][]Note, 2)
+	for _, note := range notes {
+		isOdd := note.Channel%2 != 0
+		if isOdd == a.OddIsLeft {
+			result[0] = append(result[0], note)
+		} else {
+			result[1] = append(result[1], note)
+		}
+	}
+	return result[0], result[1], nil
+}
+
+// LaneZone はレーンで手を決めます
+// レーン 1-2 は左手、4-5 は右手とし、レーン 3 は NearestFreeArm と同じ規則でその都度決めます
+type LaneZone struct {
+	Arms [2]CommandArm.Arm // 左右の腕のソレノイド配置 (ゼロ値はソレノイド1つ)
+}
+
+func (a LaneZone) Assign(notes []Note) ([]Note, []Note, error) {
+	return assignDynamic(notes, a.Arms, func(note Note, arms *[2]armState) int {
+		switch {
+		case note.TargetPos <= 2:
+			return 0
+		case note.TargetPos >= 4:
+			return 1
+		default:
+			return nearestFree(note, arms)
+		}
+	})
+}
+
+// HoldConflict はロング・スライドの途中の手にしか振り分けられなかったノーツです
+type HoldConflict struct {
+	Hand CommandArm.Hand
+	Note Note // 振り分けられなかったノーツ
+	Hold Note // 手が処理中のロング・スライドの始点
+}
+
+func (c HoldConflict) String() string {
+	return fmt.Sprintf("%s lane %d at %s during the hold from lane %d at %s", c.Hand, c.Note.TargetPos, c.Note.Position(), c.Hold.TargetPos, c.Hold.Position())
+}
+
+// HoldConflictError は両手がふさがっていて振り分けられなかったノーツの一覧です
+type HoldConflictError struct {
+	Conflicts []HoldConflict
+}
+
+func (e *HoldConflictError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		conflicts[i] = c.String()
+	}
+	return fmt.Sprintf("%d notes assigned to an arm in a hold: %s", len(e.Conflicts), strings.Join(conflicts, "; "))
+}
+
+func (a LaneZone) withArms(left, right CommandArm.Arm) HandAssigner {
+	a.Arms = [2]CommandArm.Arm{left, right}
+	return a
+}
+
+// NearestFreeArm は空いている手のうち、直前の位置が近い方に振り分けます
+// 距離が同じ場合はレーン 1-3 を左手、4-5 を右手とします
+// 同時押しは1本の腕のソレノイドで押せる間はその腕を空いているとみなします
+type NearestFreeArm struct {
+	Arms [2]CommandArm.Arm // 左右の腕のソレノイド配置 (ゼロ値はソレノイド1つ)
+}
+
+func (a NearestFreeArm) Assign(notes []Note) ([]Note, []Note, error) {
+	return assignDynamic(notes, a.Arms, nearestFree)
+}
+
+func (a NearestFreeArm) withArms(left, right CommandArm.Arm) HandAssigner {
+	a.Arms = [2]CommandArm.Arm{left, right}
+	return a
+}
+
+// armState は振り分け中の手の状態です
+type armState struct {
+	pos     int   // 直前のノーツの位置 (TargetPos 単位)
+	chain   int   // 処理中のロング・スライドのチャンネル
+	holding bool  // ロング・スライドの途中か
+	start   Note  // 処理中のロング・スライドの始点
+	last    *Note // 直前のノーツ (同時押しの判定用)
+	chord   []int // 直前のノーツと同じ時刻にこの手で押すレーン
+	chained bool  // chord にロング・スライドのノーツがあるか
+	arm     CommandArm.Arm
+}
+
+// busyAt は note の時刻にこの手が使えないかを返します
+// 同じ時刻のノーツは、ロング・スライドに属さず腕のソレノイドでまとめて押せる場合だけ使えます
+func (s *armState) busyAt(note Note) bool {
+	if s.holding {
+		return true
+	}
+	if s.last == nil || s.last.Position().Compare(note.Position()) != 0 {
+		return false
+	}
+	return s.chained || startsChain(note.Note) || !fitsLanes(s.arm, append(append([]int{}, s.chord...), note.TargetPos))
+}
+
+// 手の初期位置 (左手はレーン1の外側、右手はレーン5の外側)
+var initialArms = [2]armState{{pos: 0}, {pos: 6}}
+
+// assignDynamic は時刻順にノーツを見て choose で手を決めます
+// ロング・スライドの途中のノーツは始点と同じ手に振り分けます
+// 選んだ手が別のロング・スライドの途中の場合はそのロング・スライドを続けたままノーツを振り分け、
+// HoldConflictError として返します (ノーツ自体は返り値に残ります)
+func assignDynamic(notes []Note, armsOf [2]CommandArm.Arm, choose func(Note, *[2]armState) int) ([]Note, []Note, error) {
+	sorted := append([]Note{}, notes...)
+	SortNotes(sorted)
+
+	arms := initialArms
+	for h := range arms {
+		arms[h].arm = armsOf[h]
+	}
+	result := make([][]Note, 2)
+	conflicts := []HoldConflict{}
+	for i := range sorted {
+		note := sorted[i]
+		hand := -1
+		for h := range arms {
+			if arms[h].holding && arms[h].chain == note.Channel {
+				hand = h
+			}
+		}
+		if hand < 0 {
+			hand = choose(note, &arms)
+		}
+
+		arm := &arms[hand]
+		inChain := true
+		switch {
+		case arm.holding && arm.chain == note.Channel:
+			arm.holding = continuesChain(note.Note)
+		case arm.holding:
+			conflicts = append(conflicts, HoldConflict{Hand: handOf(hand), Note: note, Hold: arm.start})
+			result[hand] = append(result[hand], note)
+			continue
+		default:
+			arm.holding = startsChain(note.Note)
+			arm.chain = note.Channel
+			arm.start = note
+			inChain = arm.holding
+		}
+		if arm.last != nil && arm.last.Position().Compare(note.Position()) == 0 {
+			arm.chord = append(arm.chord, note.TargetPos)
+			arm.chained = arm.chained || inChain
+		} else {
+			arm.chord = []int{note.TargetPos}
+			arm.chained = inChain
+		}
+		arm.pos = note.TargetPos
+		arm.last = &sorted[i]
+		result[hand] = append(result[hand], note)
+	}
+	if len(conflicts) > 0 {
+		return result[0], result[1], &HoldConflictError{Conflicts: conflicts}
+	}
+	return result[0], result[1], nil
+}
+
+func nearestFree(note Note, arms *[2]armState) int {
+	leftFree := !arms[0].busyAt(note)
+	rightFree := !arms[1].busyAt(note)
+	switch {
+	case leftFree && !rightFree:
+		return 0
+	case rightFree && !leftFree:
+		return 1
+	}
+
+	leftDistance := abs(note.TargetPos - arms[0].pos)
+	rightDistance := abs(note.TargetPos - arms[1].pos)
+	switch {
+	case leftDistance < rightDistance:
+		return 0
+	case rightDistance < leftDistance:
+		return 1
+	case note.TargetPos <= 3:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// startsChain はノーツがロング・スライドの始点になるかを返します
+func startsChain(noteType ScoreDeleste.NoteType) bool {
+	return noteType == ScoreDeleste.LongStart || noteType == ScoreDeleste.Slide
+}
+
+// continuesChain はロング・スライドの途中のノーツの後も連結が続くかを返します
+func continuesChain(noteType ScoreDeleste.NoteType) bool {
+	return noteType == ScoreDeleste.Slide
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
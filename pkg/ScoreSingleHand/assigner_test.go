@@ -0,0 +1,211 @@
+package ScoreSingleHand
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// note は 120 BPM の 0 小節目 beat/8 拍目のノーツです (8分音符は 250ms)
+func note(channel, beat int, noteType ScoreDeleste.NoteType, pos int) Note {
+	return Note{Channel: channel, Measure: 0, BeatSet: 8, Beat: beat, Note: noteType, TargetPos: pos, TimeMs: float64(beat) * 250}
+}
+
+func lanes(notes []Note) []int {
+	result := []int{}
+	for _, n := range notes {
+		result = append(result, n.TargetPos)
+	}
+	return result
+}
+
+func TestChannelParity(t *testing.T) {
+	notes := []Note{
+		note(0, 0, ScoreDeleste.Tap, 1),
+		note(1, 1, ScoreDeleste.Tap, 5),
+		note(2, 2, ScoreDeleste.Tap, 2),
+	}
+
+	left, right, err := ChannelParity{}.Assign(notes)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, lanes(left))
+	assert.Equal(t, []int{5}, lanes(right))
+
+	left, right, err = ChannelParity{OddIsLeft: true}.Assign(notes)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, lanes(left))
+	assert.Equal(t, []int{1, 2}, lanes(right))
+}
+
+func TestLaneZone(t *testing.T) {
+	notes := []Note{
+		note(0, 0, ScoreDeleste.Tap, 2),
+		note(0, 1, ScoreDeleste.Tap, 4),
+		// 左手が直前にレーン2にいるのでレーン3は左手
+		note(0, 2, ScoreDeleste.Tap, 3),
+		// 右手のロング中のレーン3は左手、終点は右手のまま
+		note(5, 3, ScoreDeleste.LongStart, 4),
+		note(0, 4, ScoreDeleste.Tap, 3),
+		note(5, 5, ScoreDeleste.Tap, 3),
+	}
+
+	left, right, err := LaneZone{}.Assign(notes)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 3}, lanes(left))
+	assert.Equal(t, []int{4, 4, 3}, lanes(right))
+}
+
+func TestNearestFreeArm(t *testing.T) {
+	notes := []Note{
+		// 同時押しは別の手に振り分ける
+		note(0, 0, ScoreDeleste.Tap, 3),
+		note(1, 0, ScoreDeleste.Tap, 3),
+		// 左手のスライド中は右手に振り分ける
+		note(2, 2, ScoreDeleste.Slide, 1),
+		note(3, 3, ScoreDeleste.Tap, 1),
+		note(2, 4, ScoreDeleste.Slide, 2),
+		note(2, 5, ScoreDeleste.RightFlick, 2),
+		// スライドが終わったので近い左手に振り分ける
+		note(4, 6, ScoreDeleste.Tap, 2),
+	}
+
+	left, right, err := NearestFreeArm{}.Assign(notes)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 1, 2, 2, 2}, lanes(left))
+	assert.Equal(t, []int{3, 1}, lanes(right))
+}
+
+func TestHoldConflict(t *testing.T) {
+	notes := []Note{
+		// 両手ともロング中のところに別のノーツが来る
+		note(0, 0, ScoreDeleste.LongStart, 1),
+		note(1, 0, ScoreDeleste.LongStart, 5),
+		note(2, 1, ScoreDeleste.Tap, 2),
+		note(0, 2, ScoreDeleste.Tap, 1),
+		note(1, 2, ScoreDeleste.Tap, 5),
+	}
+
+	left, right, err := NearestFreeArm{}.Assign(notes)
+	var conflictErr *HoldConflictError
+	assert.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, []HoldConflict{{Hand: CommandArm.Left, Note: notes[2], Hold: notes[0]}}, conflictErr.Conflicts)
+	assert.Contains(t, err.Error(), "L lane 2 at 0+1/8 during the hold from lane 1 at 0+0/1")
+	// ロングは途中のノーツで途切れず、終点まで同じ手に残る
+	assert.Equal(t, []int{1, 2, 1}, lanes(left))
+	assert.Equal(t, []int{5, 5}, lanes(right))
+}
+
+func TestNewHandAssigner(t *testing.T) {
+	for _, name := range HandAssignerNames() {
+		assigner, err := NewHandAssigner(name)
+		assert.NoError(t, err)
+		assert.NotNil(t, assigner)
+	}
+
+	_, err := NewHandAssigner("unknown")
+	assert.Error(t, err)
+}
+
+func TestOptimal(t *testing.T) {
+	timed := func(channel, beat int, noteType ScoreDeleste.NoteType, pos int) Note {
+		n := note(channel, beat, noteType, pos)
+		n.TimeMs = float64(beat) * 250
+		return n
+	}
+
+	t.Run("arms never cross", func(t *testing.T) {
+		// チャンネルの偶奇では左手が 4、右手が 2 になり交差する
+		notes := []Note{
+			timed(0, 0, ScoreDeleste.Tap, 4),
+			timed(1, 0, ScoreDeleste.Tap, 2),
+			timed(0, 1, ScoreDeleste.Tap, 5),
+			timed(1, 1, ScoreDeleste.Tap, 1),
+		}
+		result := Optimal{}.Optimize(notes)
+		assert.Empty(t, result.Unplaced)
+		assert.Equal(t, []int{2, 1}, lanes(result.Left))
+		assert.Equal(t, []int{4, 5}, lanes(result.Right))
+		assert.Equal(t, 6.0, result.Cost.Travel)
+		assert.Greater(t, result.Cost.PeakSpeed, 0.0)
+	})
+
+	t.Run("peak speed is part of the objective", func(t *testing.T) {
+		notes := []Note{
+			timed(0, 0, ScoreDeleste.Tap, 4),
+			timed(1, 0, ScoreDeleste.Tap, 2),
+			timed(0, 1, ScoreDeleste.Tap, 5),
+			timed(1, 1, ScoreDeleste.Tap, 1),
+		}
+		base := Optimal{}.Optimize(notes)
+		doubled := Optimal{PeakWeight: 2 * DefaultPeakWeight}.Optimize(notes)
+		assert.Equal(t, base.Cost.PeakSpeed, doubled.Cost.PeakSpeed)
+		assert.InDelta(t, base.Cost.PeakSpeed*DefaultPeakWeight, doubled.Cost.Total-base.Cost.Total, 1e-9)
+	})
+
+	t.Run("chains stay on one arm", func(t *testing.T) {
+		notes := []Note{
+			timed(0, 0, ScoreDeleste.Slide, 1),
+			timed(1, 1, ScoreDeleste.Tap, 4),
+			timed(0, 2, ScoreDeleste.Slide, 3),
+			timed(0, 3, ScoreDeleste.RightFlick, 3),
+			timed(1, 4, ScoreDeleste.Tap, 2),
+		}
+		left, right, err := Optimal{}.Assign(notes)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 3, 3, 2}, lanes(left))
+		assert.Equal(t, []int{4}, lanes(right))
+	})
+
+	t.Run("reports notes that cannot be placed", func(t *testing.T) {
+		notes := []Note{
+			timed(0, 0, ScoreDeleste.Tap, 1),
+			timed(1, 0, ScoreDeleste.Tap, 3),
+			timed(2, 0, ScoreDeleste.Tap, 5),
+		}
+		left, right, err := Optimal{}.Assign(notes)
+		var unplacedErr *UnplacedError
+		assert.ErrorAs(t, err, &unplacedErr)
+		assert.Len(t, unplacedErr.Notes, 1)
+		assert.Len(t, append(left, right...), 2)
+	})
+
+	t.Run("chords that fit one arm count as one assignment", func(t *testing.T) {
+		// 2つのソレノイドを載せた左手ならレーン 1,2 をまとめて押せる
+		notes := []Note{
+			timed(0, 0, ScoreDeleste.Tap, 1),
+			timed(1, 0, ScoreDeleste.Tap, 2),
+			timed(2, 0, ScoreDeleste.Tap, 5),
+		}
+		assigner := Optimal{Arms: [2]CommandArm.Arm{CommandArm.DualTip, CommandArm.DualTip}}
+		left, right, err := assigner.Assign(notes)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, lanes(left))
+		assert.Equal(t, []int{5}, lanes(right))
+
+		// 離れたレーンはソレノイドが届かない
+		notes[1].TargetPos = 3
+		_, _, err = assigner.Assign(notes)
+		assert.Error(t, err)
+	})
+}
+
+func TestNearestFreeArmChords(t *testing.T) {
+	notes := []Note{
+		// 左手のロング中に来る同時押しは、右手のソレノイドでまとめて押す
+		note(0, 0, ScoreDeleste.LongStart, 1),
+		note(1, 1, ScoreDeleste.Tap, 4),
+		note(3, 1, ScoreDeleste.Tap, 5),
+		note(0, 2, ScoreDeleste.Tap, 1),
+	}
+
+	left, right, err := NearestFreeArm{Arms: [2]CommandArm.Arm{CommandArm.DualTip, CommandArm.DualTip}}.Assign(notes)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, lanes(left))
+	assert.Equal(t, []int{4, 5}, lanes(right))
+
+	_, _, err = ResolveChordsWithArms(left, right, CommandArm.DualTip, CommandArm.DualTip)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,223 @@
+package ScoreSingleHand
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+// ChordConflict は同じ手に同時刻のノーツが振り分けられ、解消できなかった箇所です
+type ChordConflict struct {
+	Hand     CommandArm.Hand
+	Position Position
+	Notes    []Note
+}
+
+func (c ChordConflict) String() string {
+	lanes := make([]string, len(c.Notes))
+	for i, n := range c.Notes {
+		lanes[i] = fmt.Sprint(n.TargetPos)
+	}
+	return fmt.Sprintf("%s measure %d beat %d/%d lanes %s", c.Hand, c.Position.Measure, c.Position.Num, c.Position.Den, strings.Join(lanes, ","))
+}
+
+// ChordConflictError は解消できなかった同時押しの一覧です
+type ChordConflictError struct {
+	Conflicts []ChordConflict
+}
+
+func (e *ChordConflictError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		conflicts[i] = c.String()
+	}
+	return fmt.Sprintf("%d chords assigned to a single arm: %s", len(e.Conflicts), strings.Join(conflicts, "; "))
+}
+
+// ResolveChords は同じ手に振り分けられた同時刻のノーツを検出し、
+// もう一方の手がその時刻に空いていて交差せずに届く場合はそちらに移します
+// 届くかどうかは、もう一方の手の前後のノーツとの間を ArmProfile.Default の速度で移動できるかで判定します
+// 移すのはロング・スライドに属さないノーツだけで、左手からは最も右の、右手からは最も左のノーツを移します
+// 解消できなかった箇所は ChordConflictError として返します (ノーツ自体は返り値に残ります)
+func ResolveChords(left, right []Note) ([]Note, []Note, error) {
+	return ResolveChordsWithArms(left, right, CommandArm.SingleTip, CommandArm.SingleTip)
+}
+
+// ResolveChordsWithArms は ResolveChords と同じですが、左右の腕のソレノイド配置を考慮します
+// キャリッジを1か所に置いたまま押せる同時押しはその腕に残し、各ノーツの Actuator を設定します
+func ResolveChordsWithArms(left, right []Note, leftArm, rightArm CommandArm.Arm) ([]Note, []Note, error) {
+	profile := ArmProfile.Default()
+	profile.Arms = ArmProfile.Arms{Left: leftArm, Right: rightArm}
+	return ResolveChordsWithProfile(left, right, profile)
+}
+
+// ResolveChordsWithProfile は ResolveChordsWithArms と同じですが、腕の配置と届くかどうかの判定に profile を使います
+func ResolveChordsWithProfile(left, right []Note, profile ArmProfile.Profile) ([]Note, []Note, error) {
+	hands := [2][]Note{append([]Note{}, left...), append([]Note{}, right...)}
+	arms := [2]CommandArm.Arm{profile.Arms.Left, profile.Arms.Right}
+	SortNotes(hands[0])
+	SortNotes(hands[1])
+
+	conflicts := []ChordConflict{}
+	for h := range hands {
+		other := 1 - h
+		for _, group := range Simultaneous(hands[h]) {
+			if len(group) < 2 {
+				continue
+			}
+			position := group[0].Position()
+
+			if fitChord(hands[h], group, arms[h]) {
+				continue
+			}
+
+			if moved, ok := movableNote(hands[h], group, h); ok && isFreeAt(hands[other], position) && reachable(hands[other], moved, arms[other], profile) {
+				hands[h] = removeNote(hands[h], moved)
+				hands[other] = append(hands[other], moved)
+				SortNotes(hands[other])
+				group = removeNote(group, moved)
+			}
+			if len(group) > 1 {
+				conflicts = append(conflicts, ChordConflict{
+					Hand:     handOf(h),
+					Position: position,
+					Notes:    group,
+				})
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return hands[0], hands[1], &ChordConflictError{Conflicts: conflicts}
+	}
+	return hands[0], hands[1], nil
+}
+
+// movableNote は同時押しのうちもう一方の手に移せるノーツを選びます
+func movableNote(notes []Note, group []Note, hand int) (Note, bool) {
+	chainOf, _ := chainMembership(notes)
+	inChain := map[Note]bool{}
+	for i, n := range notes {
+		if chainOf[i] >= 0 {
+			inChain[n] = true
+		}
+	}
+
+	// 左手からは右端、右手からは左端のノーツを移すと交差しない
+	order := append([]Note{}, group...)
+	if hand == 0 {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+	candidate := order[0]
+	if inChain[candidate] {
+		return Note{}, false
+	}
+	// 残るノーツと同じレーンでは交差せずに押せない
+	if order[1].TargetPos == candidate.TargetPos {
+		return Note{}, false
+	}
+	return candidate, true
+}
+
+// isFreeAt はその時刻に手がノーツを処理しておらず、ロング・スライドの途中でもないかを返します
+func isFreeAt(notes []Note, position Position) bool {
+	chainOf, isLast := chainMembership(notes)
+	holding := map[int]bool{} // position より前に始まり、まだ終わっていないロング・スライドの始点
+	for i, n := range notes {
+		c := n.Position().Compare(position)
+		if c == 0 {
+			return false
+		}
+		if c > 0 {
+			break
+		}
+		if chainOf[i] == i && !isLast[i] {
+			holding[i] = true
+		}
+		if isLast[i] {
+			delete(holding, chainOf[i])
+		}
+	}
+	return len(holding) == 0
+}
+
+// reachable は notes を押す腕が、前のノーツから target に、target から次のノーツに移動して間に合うかを返します
+// 前のノーツが無い場合は待機位置から間に合うものとします
+func reachable(notes []Note, target Note, arm CommandArm.Arm, profile ArmProfile.Profile) bool {
+	lane := carriageLane(target.TargetPos)
+	for _, n := range notes {
+		from := carriageLane(n.TargetPos - arm.Offset(n.Actuator))
+		switch c := n.Position().Compare(target.Position()); {
+		case c < 0 && profile.TravelMs(from, lane) > target.TimeMs-n.TimeMs:
+			return false
+		case c > 0:
+			return profile.TravelMs(lane, from) <= n.TimeMs-target.TimeMs
+		}
+	}
+	return true
+}
+
+// carriageLane は TargetPos の位置を CommandArm.Lane にします (レーンの外は端にします)
+func carriageLane(targetPos int) CommandArm.Lane {
+	return CommandArm.Lane1.Shift(3 * (targetPos - 1))
+}
+
+// fitChord は同時押しを腕の複数のソレノイドで押せる場合に、各ノーツの Actuator を設定します
+// ロング・スライドに属するノーツはキャリッジを動かすので対象にしません
+func fitChord(notes []Note, group []Note, arm CommandArm.Arm) bool {
+	chainOf, _ := chainMembership(notes)
+	lanes := make([]int, len(group))
+	for i, n := range group {
+		lanes[i] = n.TargetPos
+	}
+	actuators, ok := arm.Fit(lanes)
+	if !ok {
+		return false
+	}
+	indices := make([]int, len(group))
+	for i, target := range group {
+		indices[i] = -1
+		for j, n := range notes {
+			if n == target {
+				indices[i] = j
+				break
+			}
+		}
+		if indices[i] < 0 || chainOf[indices[i]] >= 0 {
+			return false
+		}
+	}
+	for i, index := range indices {
+		notes[index].Actuator = actuators[i]
+	}
+	return true
+}
+
+// fitsLanes は lanes (順不同) を腕のソレノイドでキャリッジを動かさずに押せるかを返します
+func fitsLanes(arm CommandArm.Arm, lanes []int) bool {
+	sorted := append([]int{}, lanes...)
+	sort.Ints(sorted)
+	_, ok := arm.Fit(sorted)
+	return ok
+}
+
+func removeNote(notes []Note, target Note) []Note {
+	for i, n := range notes {
+		if n == target {
+			return append(append([]Note{}, notes[:i]...), notes[i+1:]...)
+		}
+	}
+	return notes
+}
+
+func handOf(index int) CommandArm.Hand {
+	if index == 0 {
+		return CommandArm.Left
+	}
+	return CommandArm.Right
+}
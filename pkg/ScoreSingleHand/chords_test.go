@@ -0,0 +1,119 @@
+package ScoreSingleHand
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+func TestResolveChords(t *testing.T) {
+	t.Run("moves a chord note to the free arm", func(t *testing.T) {
+		left := []Note{
+			note(0, 0, ScoreDeleste.Tap, 1),
+			note(2, 0, ScoreDeleste.Tap, 4),
+		}
+		right := []Note{
+			note(1, 2, ScoreDeleste.Tap, 5),
+		}
+
+		left, right, err := ResolveChords(left, right)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, lanes(left))
+		assert.Equal(t, []int{4, 5}, lanes(right))
+	})
+
+	t.Run("reports chords that cannot be resolved", func(t *testing.T) {
+		// 右手はロングの途中なので移せない
+		left := []Note{
+			note(0, 2, ScoreDeleste.Tap, 1),
+			note(2, 2, ScoreDeleste.Tap, 2),
+		}
+		right := []Note{
+			note(1, 0, ScoreDeleste.LongStart, 5),
+			note(1, 4, ScoreDeleste.Tap, 5),
+		}
+
+		left, right, err := ResolveChords(left, right)
+		var conflictErr *ChordConflictError
+		assert.ErrorAs(t, err, &conflictErr)
+		assert.Len(t, conflictErr.Conflicts, 1)
+		assert.Equal(t, CommandArm.Left, conflictErr.Conflicts[0].Hand)
+		assert.Equal(t, Position{Measure: 0, Num: 1, Den: 4}, conflictErr.Conflicts[0].Position)
+		assert.Contains(t, err.Error(), "L measure 0 beat 1/4 lanes 1,2")
+		assert.Equal(t, []int{1, 2}, lanes(left))
+		assert.Equal(t, []int{5, 5}, lanes(right))
+	})
+
+	t.Run("reports chords whose lane the other arm cannot reach", func(t *testing.T) {
+		// 右手は 5 を押した 250ms 後に 2 へは届かない (3レーンは 400ms)
+		left := []Note{
+			note(0, 2, ScoreDeleste.Tap, 1),
+			note(2, 2, ScoreDeleste.Tap, 2),
+		}
+		right := []Note{
+			note(1, 1, ScoreDeleste.Tap, 5),
+		}
+
+		left, right, err := ResolveChords(left, right)
+		var conflictErr *ChordConflictError
+		assert.ErrorAs(t, err, &conflictErr)
+		assert.Equal(t, []int{1, 2}, lanes(left))
+		assert.Equal(t, []int{5}, lanes(right))
+	})
+
+	t.Run("checks the travel to the next note of the other arm", func(t *testing.T) {
+		// 右手は 2 を押した 250ms 後に 5 へは届かない
+		left := []Note{
+			note(0, 2, ScoreDeleste.Tap, 1),
+			note(2, 2, ScoreDeleste.Tap, 2),
+		}
+		right := []Note{
+			note(1, 3, ScoreDeleste.Tap, 5),
+		}
+
+		_, _, err := ResolveChords(left, right)
+		assert.Error(t, err)
+
+		// 次のノーツまで間が空いていれば移せる
+		right[0] = note(1, 6, ScoreDeleste.Tap, 5)
+		left, right, err = ResolveChords(left, right)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, lanes(left))
+		assert.Equal(t, []int{2, 5}, lanes(right))
+	})
+}
+
+func TestResolveChordsWithArms(t *testing.T) {
+	t.Run("keeps an adjacent chord on a dual tip arm", func(t *testing.T) {
+		left := []Note{
+			note(0, 0, ScoreDeleste.Tap, 1),
+			note(2, 0, ScoreDeleste.Tap, 2),
+		}
+		right := []Note{
+			note(1, 0, ScoreDeleste.Tap, 5),
+		}
+
+		left, right, err := ResolveChordsWithArms(left, right, CommandArm.DualTip, CommandArm.DualTip)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, lanes(left))
+		assert.Equal(t, 0, left[0].Actuator)
+		assert.Equal(t, 1, left[1].Actuator)
+		assert.Equal(t, []int{5}, lanes(right))
+	})
+
+	t.Run("falls back to the other arm when the tips do not fit", func(t *testing.T) {
+		left := []Note{
+			note(0, 0, ScoreDeleste.Tap, 1),
+			note(2, 0, ScoreDeleste.Tap, 3),
+		}
+
+		left, right, err := ResolveChordsWithArms(left, nil, CommandArm.DualTip, CommandArm.DualTip)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, lanes(left))
+		assert.Equal(t, []int{3}, lanes(right))
+		assert.Equal(t, 0, right[0].Actuator)
+	})
+}
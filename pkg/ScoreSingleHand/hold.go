@@ -0,0 +1,123 @@
+package ScoreSingleHand
+
+import "github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+
+// EndType はロングノーツ・スライドの終点での離し方です
+type EndType int
+
+const (
+	EndRelease    EndType = iota + 1 // その場で離す
+	EndLeftFlick                     // 左にフリックして離す
+	EndRightFlick                    // 右にフリックして離す
+)
+
+// Hold はロングノーツ・スライドを始点から終点まで1つにまとめたものです
+type Hold struct {
+	Start Note   // 始点 (LongStart または Slide)
+	Path  []Note // 中継点 (始点・終点を含まない、スライドのみ)
+	End   Note   // 終点
+	// Inside は始点から終点までの間に始まる同じ手の別の動作です
+	// 押さえたまま処理できないので動作の列には含めません
+	Inside []Action
+}
+
+// IsSlide はスライドかを返します
+func (h Hold) IsSlide() bool {
+	return h.Start.Note == ScoreDeleste.Slide
+}
+
+// EndType は終点での離し方を返します
+func (h Hold) EndType() EndType {
+	switch h.End.Note {
+	case ScoreDeleste.LeftFlick:
+		return EndLeftFlick
+	case ScoreDeleste.RightFlick:
+		return EndRightFlick
+	default:
+		return EndRelease
+	}
+}
+
+// StartTimeMs は始点の時間 (ミリ秒) を返します
+func (h Hold) StartTimeMs() float64 {
+	return h.Start.TimeMs
+}
+
+// EndTimeMs は終点の時間 (ミリ秒) を返します
+func (h Hold) EndTimeMs() float64 {
+	return h.End.TimeMs
+}
+
+// Points は始点・中継点・終点を順に返します
+func (h Hold) Points() []Note {
+	points := append([]Note{h.Start}, h.Path...)
+	if h.End != h.Start {
+		points = append(points, h.End)
+	}
+	return points
+}
+
+// Action は片手の処理単位で、単独のノーツかロング・スライドのどちらかです
+type Action struct {
+	Note Note  // 単独のノーツ、またはロング・スライドの始点
+	Hold *Hold // ロング・スライドの場合のみ
+}
+
+// IsGap は区切り (None) かを返します
+func (a Action) IsGap() bool {
+	return a.Hold == nil && a.Note.Note == ScoreDeleste.None
+}
+
+// Last は動作の最後のノーツ (ロング・スライドなら終点) を返します
+func (a Action) Last() Note {
+	if a.Hold != nil {
+		return a.Hold.End
+	}
+	return a.Note
+}
+
+// BuildActions は片手分の時刻順のノーツから、ロング・スライドを1つにまとめた動作の列を作ります
+// ロングは同じチャンネルの次のノーツを終点とし、スライドはスライドかフリックが続く間を1本とします
+// None は区切りとしてそのまま残します
+// 終点の無いロング・スライドは最後のノーツを終点とします
+// ロング・スライドの始点から終点までの間に始まる動作は列に含めず、Hold.Inside に残します
+func BuildActions(notes []Note) []Action {
+	chainOf, isLast := chainMembership(notes)
+	ends := map[int]Note{} // 始点の番号 -> 終点
+	for i, note := range notes {
+		if chainOf[i] >= 0 && isLast[i] {
+			ends[chainOf[i]] = note
+		}
+	}
+
+	actions := []Action{}
+	holds := map[int]*Hold{} // 始点の番号 -> ロング・スライド
+	open := -1               // 押さえている途中のロング・スライドの始点の番号
+	for i, note := range notes {
+		chain := chainOf[i]
+		if chain >= 0 && chain != i {
+			hold := holds[chain]
+			if hold.End != hold.Start {
+				hold.Path = append(hold.Path, hold.End)
+			}
+			hold.End = note
+			continue
+		}
+
+		action := Action{Note: note}
+		if chain == i {
+			action.Hold = &Hold{Start: note, End: note}
+			holds[i] = action.Hold
+		}
+		if open >= 0 && note.Position().Compare(ends[open].Position()) < 0 {
+			holds[open].Inside = append(holds[open].Inside, action)
+			continue
+		}
+		open = -1
+		if chain == i && ends[i] != note {
+			open = i
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
@@ -0,0 +1,78 @@
+package ScoreSingleHand
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+func TestBuildActions(t *testing.T) {
+	t.Run("long note spans to the next note in its channel", func(t *testing.T) {
+		notes := []Note{
+			note(1, 0, ScoreDeleste.LongStart, 2),
+			note(3, 2, ScoreDeleste.Tap, 4),
+			note(1, 4, ScoreDeleste.LeftFlick, 2),
+		}
+
+		// 途中のタップは押さえたまま押せないので、動作の列ではなく Inside に残る
+		actions := BuildActions(notes)
+		assert.Len(t, actions, 1)
+		assert.NotNil(t, actions[0].Hold)
+		assert.Equal(t, notes[0], actions[0].Hold.Start)
+		assert.Equal(t, notes[2], actions[0].Hold.End)
+		assert.Empty(t, actions[0].Hold.Path)
+		assert.Equal(t, EndLeftFlick, actions[0].Hold.EndType())
+		assert.False(t, actions[0].Hold.IsSlide())
+		assert.Equal(t, []Action{{Note: notes[1]}}, actions[0].Hold.Inside)
+	})
+
+	t.Run("notes from the end of a hold are actions again", func(t *testing.T) {
+		notes := []Note{
+			note(1, 0, ScoreDeleste.LongStart, 2),
+			note(3, 1, ScoreDeleste.LongStart, 4),
+			note(3, 3, ScoreDeleste.Tap, 4),
+			note(1, 4, ScoreDeleste.Tap, 2),
+			note(5, 4, ScoreDeleste.Tap, 3),
+		}
+
+		// 途中に始まるロングは終点まで丸ごと Inside に入る
+		actions := BuildActions(notes)
+		assert.Len(t, actions, 2)
+		inside := actions[0].Hold.Inside
+		assert.Len(t, inside, 1)
+		assert.Equal(t, notes[1], inside[0].Hold.Start)
+		assert.Equal(t, notes[2], inside[0].Hold.End)
+		assert.Equal(t, notes[4], actions[1].Note)
+	})
+
+	t.Run("slide keeps relay points as its path", func(t *testing.T) {
+		notes := []Note{
+			note(1, 0, ScoreDeleste.Slide, 1),
+			note(1, 2, ScoreDeleste.Slide, 2),
+			note(1, 4, ScoreDeleste.Slide, 3),
+			note(1, 6, ScoreDeleste.Slide, 4),
+			note(1, 7, ScoreDeleste.Tap, 4),
+		}
+
+		actions := BuildActions(notes)
+		assert.Len(t, actions, 2)
+		hold := actions[0].Hold
+		assert.True(t, hold.IsSlide())
+		assert.Equal(t, []int{2, 3}, lanes(hold.Path))
+		assert.Equal(t, notes[3], hold.End)
+		assert.Equal(t, EndRelease, hold.EndType())
+		assert.Equal(t, []int{1, 2, 3, 4}, lanes(hold.Points()))
+		assert.Equal(t, notes[4], actions[1].Last())
+	})
+
+	t.Run("unterminated long note ends at its start", func(t *testing.T) {
+		notes := []Note{note(1, 0, ScoreDeleste.LongStart, 3)}
+
+		actions := BuildActions(notes)
+		assert.Len(t, actions, 1)
+		assert.Equal(t, notes[0], actions[0].Last())
+		assert.Equal(t, []int{3}, lanes(actions[0].Hold.Points()))
+	})
+}
@@ -0,0 +1,338 @@
+package ScoreSingleHand
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// Optimal の既定の重み
+const (
+	DefaultSpeedWeight  = 0.01   // 速度 (レーン/秒) の2乗にかける重み
+	DefaultPeakWeight   = 1.0    // 必要な移動速度の最大値 (レーン/秒) にかける重み
+	DefaultUnplacedCost = 1000.0 // 振り分けられなかったノーツ1つあたりのコスト
+)
+
+// Cost は振り分け結果の評価値です
+type Cost struct {
+	Travel    float64 // 両手の移動距離の合計 (レーン)
+	PeakSpeed float64 // 必要な移動速度の最大値 (レーン/秒)
+	Total     float64 // 最小化した目的関数の値
+}
+
+// Assignment は振り分け結果です
+type Assignment struct {
+	Left     []Note
+	Right    []Note
+	Unplaced []Note // どちらの手にも振り分けられなかったノーツ
+	Cost     *Cost  // Optimal の評価値 (他の振り分け方では nil)
+}
+
+// UnplacedError は振り分けられなかったノーツがあることを表します
+type UnplacedError struct {
+	Notes []Note
+}
+
+func (e *UnplacedError) Error() string {
+	positions := make([]string, len(e.Notes))
+	for i, n := range e.Notes {
+		positions[i] = fmt.Sprintf("%d:%d/%d lane %d", n.Measure, n.Beat, n.BeatSet, n.TargetPos)
+	}
+	return fmt.Sprintf("%d notes could not be placed: %s", len(e.Notes), strings.Join(positions, ", "))
+}
+
+// Optimal は譜面全体を見て移動量と必要速度が最小になるように振り分けます
+// 目的関数は移動ごとの距離と速度の2乗の和に、必要な移動速度の最大値を加えたものです
+// 同時刻のノーツをまとめて時刻順に動的計画法で解き、状態は両手の位置と処理中のロング・スライドです
+// 最大値の項は状態ごとにそれまでの最大値で比べるため、厳密な最小ではなく近似になります
+// 左手は常に右手より左にあり、ロング・スライドは始点から終点まで同じ手で処理します
+// 腕のソレノイドでまとめて押せる同時押しは1本の腕に振り分けられます
+type Optimal struct {
+	SpeedWeight  float64           // 0 の場合は DefaultSpeedWeight
+	PeakWeight   float64           // 0 の場合は DefaultPeakWeight
+	UnplacedCost float64           // 0 の場合は DefaultUnplacedCost
+	Arms         [2]CommandArm.Arm // 左右の腕のソレノイド配置 (ゼロ値はソレノイド1つ)
+}
+
+func (o Optimal) Assign(notes []Note) ([]Note, []Note, error) {
+	result := o.Optimize(notes)
+	if len(result.Unplaced) > 0 {
+		return result.Left, result.Right, &UnplacedError{Notes: result.Unplaced}
+	}
+	return result.Left, result.Right, nil
+}
+
+func (o Optimal) withArms(left, right CommandArm.Arm) HandAssigner {
+	o.Arms = [2]CommandArm.Arm{left, right}
+	return o
+}
+
+// 振り分け先 (0: 左手, 1: 右手, unplaced: 振り分けない)
+const unplaced = 2
+
+type dpKey struct {
+	pos   [2]int // 両手の位置 (TargetPos 単位)
+	chain [2]int // 処理中のロング・スライドの始点の番号 (-1 は無し)
+}
+
+type dpNode struct {
+	cost     float64
+	travel   float64
+	peak     float64
+	lastTime [2]float64 // 各手が最後にノーツを処理した時刻
+	assign   []int      // グループ内の各ノーツの振り分け先
+	prev     *dpNode
+}
+
+// Optimize はノーツを振り分け、評価値と振り分けられなかったノーツを返します
+func (o Optimal) Optimize(notes []Note) Assignment {
+	speedWeight := o.SpeedWeight
+	if speedWeight == 0 {
+		speedWeight = DefaultSpeedWeight
+	}
+	peakWeight := o.PeakWeight
+	if peakWeight == 0 {
+		peakWeight = DefaultPeakWeight
+	}
+	unplacedCost := o.UnplacedCost
+	if unplacedCost == 0 {
+		unplacedCost = DefaultUnplacedCost
+	}
+
+	sorted := append([]Note{}, notes...)
+	SortNotes(sorted)
+	chainOf, isLast := chainMembership(sorted)
+
+	// 同時刻のノーツをグループにまとめる
+	groups := [][]int{}
+	for i := range sorted {
+		if i > 0 && sorted[i-1].Position().Compare(sorted[i].Position()) == 0 {
+			groups[len(groups)-1] = append(groups[len(groups)-1], i)
+		} else {
+			groups = append(groups, []int{i})
+		}
+	}
+
+	// 最初のノーツへの移動は待機位置からなので速度を問わない
+	start := &dpNode{lastTime: [2]float64{math.Inf(-1), math.Inf(-1)}}
+	states := map[dpKey]*dpNode{
+		{pos: [2]int{initialArms[0].pos, initialArms[1].pos}, chain: [2]int{-1, -1}}: start,
+	}
+
+	for _, group := range groups {
+		next := map[dpKey]*dpNode{}
+		timeMs := sorted[group[0]].TimeMs
+
+		for _, key := range sortedKeys(states) {
+			node := states[key]
+			forEachAssignment(len(group), func(assign []int) {
+				newKey := key
+				newNode := dpNode{
+					cost:     node.cost,
+					travel:   node.travel,
+					peak:     node.peak,
+					lastTime: node.lastTime,
+					prev:     node,
+				}
+				taken := [2][]int{} // 各手に振り分けたノーツの番号
+
+				for j, index := range group {
+					hand := assign[j]
+					chain := chainOf[index]
+					isStart := chain == index
+					isContinuation := chain >= 0 && !isStart
+
+					if hand == unplaced {
+						// ロング・スライドの最後のノーツを落とした場合は手を解放する
+						if isContinuation && isLast[index] {
+							for h := range newKey.chain {
+								if key.chain[h] == chain {
+									newKey.chain[h] = -1
+								}
+							}
+						}
+						newNode.cost += unplacedCost
+						continue
+					}
+					taken[hand] = append(taken[hand], index)
+
+					// ロング・スライドの途中はその手でしか処理できず、その手は他のノーツを処理できない
+					if isContinuation && key.chain[hand] != chain {
+						return
+					}
+					if !isContinuation && key.chain[hand] >= 0 {
+						return
+					}
+
+					switch {
+					case isLast[index]:
+						newKey.chain[hand] = -1
+					default:
+						newKey.chain[hand] = chain
+					}
+				}
+
+				for hand, indices := range taken {
+					if len(indices) == 0 {
+						continue
+					}
+					pos, ok := o.carriage(sorted, indices, chainOf, hand)
+					if !ok {
+						return
+					}
+					distance := math.Abs(float64(pos - key.pos[hand]))
+					if distance > 0 {
+						dt := math.Max((timeMs-node.lastTime[hand])/1000.0, 0.001)
+						speed := distance / dt
+						newNode.cost += distance + speedWeight*speed*speed
+						newNode.travel += distance
+						if speed > newNode.peak {
+							newNode.cost += peakWeight * (speed - newNode.peak)
+							newNode.peak = speed
+						}
+					}
+					newKey.pos[hand] = pos
+					newNode.lastTime[hand] = timeMs
+				}
+
+				// 手は交差できない (左手のソレノイドは基準より右にも並ぶ)
+				if newKey.pos[0]+o.Arms[0].Span() >= newKey.pos[1] {
+					return
+				}
+
+				if existing, ok := next[newKey]; !ok || newNode.cost < existing.cost {
+					newNode.assign = append([]int{}, assign...)
+					next[newKey] = &newNode
+				}
+			})
+		}
+		states = next
+	}
+
+	var best *dpNode
+	for _, key := range sortedKeys(states) {
+		if node := states[key]; best == nil || node.cost < best.cost {
+			best = node
+		}
+	}
+
+	result := Assignment{
+		Cost: &Cost{Travel: best.travel, PeakSpeed: best.peak, Total: best.cost},
+	}
+	hands := make([]int, len(sorted))
+	node := best
+	for g := len(groups) - 1; g >= 0; g-- {
+		for j, index := range groups[g] {
+			hands[index] = node.assign[j]
+		}
+		node = node.prev
+	}
+	for i, hand := range hands {
+		switch hand {
+		case 0:
+			result.Left = append(result.Left, sorted[i])
+		case 1:
+			result.Right = append(result.Right, sorted[i])
+		default:
+			result.Unplaced = append(result.Unplaced, sorted[i])
+		}
+	}
+	return result
+}
+
+// carriage は1本の腕に振り分けた同時刻のノーツを押すキャリッジの位置 (TargetPos 単位) を返します
+// 2つ以上のノーツは、ロング・スライドに属さず腕のソレノイドでまとめて押せる場合だけ振り分けられます
+func (o Optimal) carriage(sorted []Note, indices []int, chainOf []int, hand int) (int, bool) {
+	if len(indices) == 1 {
+		return sorted[indices[0]].TargetPos, true
+	}
+	lanes := make([]int, len(indices))
+	for i, index := range indices {
+		if chainOf[index] >= 0 {
+			return 0, false
+		}
+		lanes[i] = sorted[index].TargetPos
+	}
+	sort.Ints(lanes)
+	actuators, ok := o.Arms[hand].Fit(lanes)
+	if !ok {
+		return 0, false
+	}
+	return lanes[0] - o.Arms[hand].Offset(actuators[0]), true
+}
+
+// sortedKeys は結果が実行ごとに変わらないように状態を一定の順序で返します
+func sortedKeys(states map[dpKey]*dpNode) []dpKey {
+	keys := make([]dpKey, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		for h := 0; h < 2; h++ {
+			if a.pos[h] != b.pos[h] {
+				return a.pos[h] < b.pos[h]
+			}
+			if a.chain[h] != b.chain[h] {
+				return a.chain[h] < b.chain[h]
+			}
+		}
+		return false
+	})
+	return keys
+}
+
+// forEachAssignment は n 個のノーツの振り分け先の組み合わせをすべて列挙します
+func forEachAssignment(n int, f func([]int)) {
+	assign := make([]int, n)
+	var walk func(int)
+	walk = func(i int) {
+		if i == n {
+			f(assign)
+			return
+		}
+		for hand := 0; hand <= unplaced; hand++ {
+			assign[i] = hand
+			walk(i + 1)
+		}
+	}
+	walk(0)
+}
+
+// chainMembership は時刻順のノーツについて、属するロング・スライドの始点の番号 (-1 は単独) と
+// それがロング・スライドの最後のノーツかを返します
+func chainMembership(sorted []Note) ([]int, []bool) {
+	chainOf := make([]int, len(sorted))
+	isLast := make([]bool, len(sorted))
+	active := map[int]int{} // チャンネル -> 始点の番号
+	last := map[int]int{}   // 始点の番号 -> 最後のノーツの番号
+
+	for i, note := range sorted {
+		chainOf[i] = -1
+		if start, ok := active[note.Channel]; ok {
+			// ロングは次のノーツで終わり、スライドはスライドかフリックが続く間つながる
+			if sorted[start].Note == ScoreDeleste.LongStart || note.Note == ScoreDeleste.Slide || note.Note == ScoreDeleste.LeftFlick || note.Note == ScoreDeleste.RightFlick {
+				chainOf[i] = start
+				last[start] = i
+				if sorted[start].Note == ScoreDeleste.LongStart || !continuesChain(note.Note) {
+					delete(active, note.Channel)
+				}
+				continue
+			}
+			delete(active, note.Channel)
+		}
+		if startsChain(note.Note) {
+			chainOf[i] = i
+			last[i] = i
+			active[note.Channel] = i
+		}
+	}
+	for _, i := range last {
+		isLast[i] = true
+	}
+	return chainOf, isLast
+}
@@ -0,0 +1,86 @@
+package ScoreSingleHand
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+// Position は譜面上の位置を「小節数 + 既約分数」で正確に表します
+type Position struct {
+	Measure int // 小節数
+	Num     int // 小節内の位置の分子 (0 <= Num < Den)
+	Den     int // 小節内の位置の分母 (1 以上)
+}
+
+// NewPosition は measure + beat/beatSet を既約分数の位置に変換します
+func NewPosition(measure, beat, beatSet int) Position {
+	if beatSet <= 0 {
+		return Position{Measure: measure, Num: 0, Den: 1}
+	}
+	measure += beat / beatSet
+	beat %= beatSet
+	g := ScoreDeleste.GCD(beat, beatSet)
+	return Position{Measure: measure, Num: beat / g, Den: beatSet / g}
+}
+
+// Compare は位置を比較し、p が先なら負、同時なら 0、後なら正を返します
+func (p Position) Compare(q Position) int {
+	return ScoreDeleste.CompareBeats(p.Measure, p.Num, p.Den, q.Measure, q.Num, q.Den)
+}
+
+// Measures は位置を小節単位の実数で返します
+func (p Position) Measures() float64 {
+	return float64(p.Measure) + float64(p.Num)/float64(p.Den)
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d+%d/%d", p.Measure, p.Num, p.Den)
+}
+
+// Position はノーツの位置を返します
+func (n Note) Position() Position {
+	return NewPosition(n.Measure, n.Beat, n.BeatSet)
+}
+
+// ID はノーツを譜面の中で一意に表す文字列 (位置@レーン#チャンネル) を返します
+// 位置は既約分数で表すので、タイミング文字列の分割数や行の順序が変わっても同じ値になります
+func (n Note) ID() string {
+	return fmt.Sprintf("%s@%d#%d", n.Position(), n.TargetPos, n.Channel)
+}
+
+// compareNotes はノーツの並び順を決めます
+// 位置、レーン、チャンネル、ノートタイプの順に比べるので、同時刻のノーツも常に同じ順に並びます
+func compareNotes(a, b Note) int {
+	if c := a.Position().Compare(b.Position()); c != 0 {
+		return c
+	}
+	if a.TargetPos != b.TargetPos {
+		return a.TargetPos - b.TargetPos
+	}
+	if a.Channel != b.Channel {
+		return a.Channel - b.Channel
+	}
+	return int(a.Note) - int(b.Note)
+}
+
+// SortNotes はノーツを時刻順に並べ替えます
+func SortNotes(notes []Note) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		return compareNotes(notes[i], notes[j]) < 0
+	})
+}
+
+// Simultaneous は時刻順に並んだノーツを同時刻ごとのまとまりに分けます
+func Simultaneous(notes []Note) [][]Note {
+	groups := [][]Note{}
+	for i, note := range notes {
+		if i > 0 && notes[i-1].Position().Compare(note.Position()) == 0 {
+			groups[len(groups)-1] = append(groups[len(groups)-1], note)
+		} else {
+			groups = append(groups, []Note{note})
+		}
+	}
+	return groups
+}
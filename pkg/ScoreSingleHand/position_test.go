@@ -0,0 +1,53 @@
+package ScoreSingleHand
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
+
+func TestPosition(t *testing.T) {
+	assert.Equal(t, Position{Measure: 1, Num: 1, Den: 2}, NewPosition(1, 4, 8))
+	assert.Equal(t, Position{Measure: 2, Num: 0, Den: 1}, NewPosition(1, 4, 4))
+	assert.Equal(t, 0, NewPosition(0, 1, 3).Compare(NewPosition(0, 2, 6)))
+	assert.Less(t, NewPosition(0, 1, 3).Compare(NewPosition(0, 3, 8)), 0)
+	assert.Greater(t, NewPosition(1, 0, 4).Compare(NewPosition(0, 15, 16)), 0)
+}
+
+func TestNoteID(t *testing.T) {
+	note := Note{Channel: 2, Measure: 1, Beat: 4, BeatSet: 8, Note: ScoreDeleste.Tap, TargetPos: 3}
+	assert.Equal(t, "1+1/2@3#2", note.ID())
+
+	// 分割数が違っても同じ位置なら同じ ID
+	same := note
+	same.Beat, same.BeatSet = 2, 4
+	assert.Equal(t, note.ID(), same.ID())
+}
+
+func TestConvertFromDelesteOrdering(t *testing.T) {
+	// 小節・チャンネルの順序がばらばらの譜面
+	score := &ScoreDeleste.Score{
+		Header: ScoreDeleste.Header{BPM: 120},
+		Notes: []ScoreDeleste.Note{
+			{Channel: 2, Measure: 1, Note: []ScoreDeleste.NoteType{2, 0}, TargetPos: []int{3}},
+			{Channel: 2, Measure: 0, Note: []ScoreDeleste.NoteType{0, 2}, TargetPos: []int{2}},
+			{Channel: 0, Measure: 0, Note: []ScoreDeleste.NoteType{2, 0, 0, 2}, TargetPos: []int{1, 4}},
+			{Channel: 1, Measure: 0, Note: []ScoreDeleste.NoteType{0, 0, 2, 0}, TargetPos: []int{5}},
+		},
+	}
+
+	left, right, err := ConvertFromDeleste(score, ChannelParity{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4, 3}, lanes(left))
+	assert.Equal(t, []int{5}, lanes(right))
+	for i := 1; i < len(left); i++ {
+		assert.LessOrEqual(t, left[i-1].TimeMs, left[i].TimeMs)
+	}
+
+	// 0小節目の 2/4 に左手のレーン2と右手のレーン5が同時にある
+	groups := Simultaneous(Flatten(score))
+	assert.Len(t, groups, 4)
+	assert.Equal(t, []int{2, 5}, lanes(groups[1]))
+}
@@ -1,24 +1,74 @@
 package ScoreSingleHand
 
-import "github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+import (
+	"errors"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ScoreDeleste"
+)
 
 type Note struct {
+	Channel   int // 元の譜面のチャンネル番号 (ロング・スライドの連結に使う)
 	Measure   int // この音符が何小節目かを示す
 	BeatSet   int // この小節が何拍子で表現されているかを示す
 	Beat      int // この音符が何拍目かを示す
 	Note      ScoreDeleste.NoteType
 	TargetPos int
+	TimeMs    float64 // 譜面先頭からの時間 (ミリ秒、テンポ変化を含む)
+	Actuator  int     // このノーツを押すソレノイドの番号 (CommandArm.Arm の Offsets の添字)
 }
 
 // ConvertFromDeleste は ScoreDeleste のデータを ScoreSingleHand.Score に変換します
-// 1つ目の戻り値は奇数チャンネル（左手）、2つ目の戻り値は偶数チャンネル（右手）を抽出します
-func ConvertFromDeleste(deleste *ScoreDeleste.Score) ([]Note, []Note, error) {
-	result := make([][]Note, 2)
+// 各ノーツをどちらの手で処理するかは assigner で決めます (nil の場合は DefaultHandAssigner)
+// 同じ手に振り分けられた同時押しは ResolveChords で解消し、解消できなければエラーを返します
+// 1つ目の戻り値は左手、2つ目の戻り値は右手のノーツで、どちらも SortNotes の順に並びます
+func ConvertFromDeleste(deleste *ScoreDeleste.Score, assigner HandAssigner) ([]Note, []Note, error) {
+	return ConvertFromDelesteWithArms(deleste, assigner, CommandArm.SingleTip, CommandArm.SingleTip)
+}
 
-	for _, note := range deleste.Notes {
-		// チャンネル番号の奇数/偶数判定
-		channelIsRight := note.Channel%2 == 1
+// ConvertFromDelesteWithArms は ConvertFromDeleste と同じですが、左右の腕のソレノイド配置を指定します
+// ソレノイドが複数ある腕では、キャリッジを動かさずに押せる同時押しをその腕のまま残します
+// 振り分け方が腕の配置を扱える場合 (LaneZone, NearestFreeArm, Optimal) は、その同時押しを初めから1本の腕に振り分けます
+func ConvertFromDelesteWithArms(deleste *ScoreDeleste.Score, assigner HandAssigner, leftArm, rightArm CommandArm.Arm) ([]Note, []Note, error) {
+	profile := ArmProfile.Default()
+	profile.Arms = ArmProfile.Arms{Left: leftArm, Right: rightArm}
+	assignment, err := AssignScore(deleste, assigner, profile)
+	return assignment.Left, assignment.Right, err
+}
+
+// AssignScore は ConvertFromDelesteWithArms と同じ振り分けを行い、結果を Assignment で返します
+// 腕の配置と、同時押しをもう一方の腕に移すときに届くかどうかの判定には profile を使います
+// Optimal で振り分けた場合は、振り分けに使った評価値を Cost に入れます
+func AssignScore(deleste *ScoreDeleste.Score, assigner HandAssigner, profile ArmProfile.Profile) (Assignment, error) {
+	if assigner == nil {
+		assigner = DefaultHandAssigner
+	}
+	if armed, ok := assigner.(armAssigner); ok {
+		assigner = armed.withArms(profile.Arms.Left, profile.Arms.Right)
+	}
 
+	var assignment Assignment
+	var assignErr error
+	if optimal, ok := assigner.(Optimal); ok {
+		assignment = optimal.Optimize(Flatten(deleste))
+		if len(assignment.Unplaced) > 0 {
+			assignErr = &UnplacedError{Notes: assignment.Unplaced}
+		}
+	} else {
+		assignment.Left, assignment.Right, assignErr = assigner.Assign(Flatten(deleste))
+	}
+	var chordErr error
+	assignment.Left, assignment.Right, chordErr = ResolveChordsWithProfile(assignment.Left, assignment.Right, profile)
+	return assignment, errors.Join(assignErr, chordErr)
+}
+
+// Flatten は ScoreDeleste のノーツを1つずつの Note に展開し、SortNotes の順に並べて返します
+// 行の順序 (チャンネル・小節の並び) には依存しません
+func Flatten(deleste *ScoreDeleste.Score) []Note {
+	result := []Note{}
+
+	for _, note := range deleste.Notes {
 		measureNumber := note.Measure
 		beatSet := len(note.Note)
 
@@ -28,20 +78,19 @@ func ConvertFromDeleste(deleste *ScoreDeleste.Score) ([]Note, []Note, error) {
 				continue
 			}
 			singleNote := Note{
+				Channel:   note.Channel,
 				Measure:   measureNumber,
 				BeatSet:   beatSet,
 				Beat:      beatNumber,
 				Note:      beat,
 				TargetPos: note.TargetPos[count],
+				TimeMs:    deleste.TimeMs(measureNumber, beatNumber, beatSet),
 			}
-			if channelIsRight {
-				result[1] = append(result[1], singleNote)
-			} else {
-				result[0] = append(result[0], singleNote)
-			}
+			result = append(result, singleNote)
 			count++
 		}
 	}
 
-	return result[0], result[1], nil
+	SortNotes(result)
+	return result
 }
@@ -0,0 +1,233 @@
+package Timeline
+
+import (
+	"math"
+	"sort"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+// Move は1回の移動です
+// 移動は指令された時刻か前の移動が終わった時刻の遅い方に止まった状態から始まり、
+// ArmProfile.Profile の速度・加速度で最短時間で動くものとします
+// 到着時刻 (endTime) の指定がある移動は、最短時間より後に着く場合は速度変化を同じ形のまま引き延ばしてその時刻に着くものとします
+type Move struct {
+	Hand      CommandArm.Hand
+	CommandMs float64 // 指令された時刻
+	StartMs   float64 // 動き始める時刻
+	EndMs     float64 // 着く時刻
+	From, To  CommandArm.Lane
+	Source    *CommandArm.Source
+}
+
+// Press はソレノイドを押している時間帯です (EndMs は離さずに終わる場合は +Inf)
+type Press struct {
+	Hand     CommandArm.Hand
+	Actuator int
+	StartMs  float64
+	EndMs    float64
+	Lane     CommandArm.Lane    // 押す前に指令された移動の行き先
+	ReadyMs  float64            // 押す前に指令された移動が終わる時刻 (StartMs より後なら間に合っていない)
+	Source   *CommandArm.Source // 押したコマンドの元のノーツ
+}
+
+// HandState はある時刻の片手の状態です
+type HandState struct {
+	Position  float64         // レーン単位の位置 (CommandArm.Lane.Position と同じ単位、移動中は補間)
+	Lane      CommandArm.Lane // 最後に向かった (向かっている) レーン
+	Moving    bool
+	Pressed   bool
+	Actuators []int // 押しているソレノイドの番号
+}
+
+// State はある時刻の両手の状態です
+type State struct {
+	Left  HandState
+	Right HandState
+}
+
+// ChangeKind は状態変化の種類です
+type ChangeKind int
+
+const (
+	MoveStart ChangeKind = iota + 1
+	MoveEnd
+	PressOn
+	PressOff
+)
+
+// Change は1つの状態変化です
+type Change struct {
+	TimeMs   float64
+	Hand     CommandArm.Hand
+	Kind     ChangeKind
+	Lane     CommandArm.Lane // 移動の場合の行き先
+	Actuator int             // 押し・離しの場合のソレノイドの番号
+	Source   *CommandArm.Source
+}
+
+// Timeline は左右のコマンド列から作った腕の動きで、腕の位置と押し状態の意味はここで決めます
+type Timeline struct {
+	profile ArmProfile.Profile
+	moves   [2][]Move
+	presses [2][]Press
+}
+
+// New は左右のコマンド列から Timeline を作ります (各列は時刻順に並べ直します)
+// 腕は待機位置から始まります
+func New(left, right []CommandArm.Command, profile ArmProfile.Profile) *Timeline {
+	timeline := &Timeline{profile: profile}
+	for i, hand := range []CommandArm.Hand{CommandArm.Left, CommandArm.Right} {
+		commands := append([]CommandArm.Command{}, [][]CommandArm.Command{left, right}[i]...)
+		sort.SliceStable(commands, func(a, b int) bool {
+			return commands[a].TimeMs() < commands[b].TimeMs()
+		})
+		timeline.moves[i], timeline.presses[i] = replay(commands, hand, profile)
+	}
+	return timeline
+}
+
+func replay(commands []CommandArm.Command, hand CommandArm.Hand, profile ArmProfile.Profile) ([]Move, []Press) {
+	moves := []Move{}
+	presses := []Press{}
+	position := profile.HomeOf(hand)
+	sinceMs := math.Inf(-1)
+	open := map[int]int{} // ソレノイド -> presses 内の押している区間
+
+	for _, command := range commands {
+		switch c := command.(type) {
+		case *CommandArm.CommandMove:
+			startMs := math.Max(float64(c.TimeMs()), sinceMs)
+			endMs := startMs + profile.TravelMs(position, c.Lane())
+			if deadlineMs, ok := c.EndTime(); ok {
+				endMs = math.Max(endMs, float64(deadlineMs))
+			}
+			moves = append(moves, Move{
+				Hand:      hand,
+				CommandMs: float64(c.TimeMs()),
+				StartMs:   startMs,
+				EndMs:     endMs,
+				From:      position,
+				To:        c.Lane(),
+				Source:    CommandArm.SourceOf(c),
+			})
+			position = c.Lane()
+			sinceMs = endMs
+		case *CommandArm.CommandSolenoid:
+			index, pressed := open[c.Actuator()]
+			switch {
+			case c.State() && !pressed:
+				open[c.Actuator()] = len(presses)
+				presses = append(presses, Press{
+					Hand:     hand,
+					Actuator: c.Actuator(),
+					StartMs:  float64(c.TimeMs()),
+					EndMs:    math.Inf(1),
+					Lane:     position,
+					ReadyMs:  sinceMs,
+					Source:   CommandArm.SourceOf(c),
+				})
+			case !c.State() && pressed:
+				presses[index].EndMs = float64(c.TimeMs())
+				delete(open, c.Actuator())
+			}
+		}
+	}
+	return moves, presses
+}
+
+func index(hand CommandArm.Hand) int {
+	if hand == CommandArm.Left {
+		return 0
+	}
+	return 1
+}
+
+// Moves は hand の移動を時刻順に返します
+func (t *Timeline) Moves(hand CommandArm.Hand) []Move {
+	return t.moves[index(hand)]
+}
+
+// Presses は hand が fromMs から toMs の間に押している時間帯を押した順に返します
+func (t *Timeline) Presses(hand CommandArm.Hand, fromMs, toMs float64) []Press {
+	result := []Press{}
+	for _, p := range t.presses[index(hand)] {
+		if p.StartMs <= toMs && fromMs < p.EndMs {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// StateAt は timeMs の両手の状態を返します
+func (t *Timeline) StateAt(timeMs float64) State {
+	return State{
+		Left:  t.handStateAt(CommandArm.Left, timeMs),
+		Right: t.handStateAt(CommandArm.Right, timeMs),
+	}
+}
+
+func (t *Timeline) handStateAt(hand CommandArm.Hand, timeMs float64) HandState {
+	lane := t.profile.HomeOf(hand)
+	state := HandState{Position: lane.Position(), Lane: lane, Actuators: []int{}}
+	for _, m := range t.moves[index(hand)] {
+		if m.StartMs > timeMs {
+			break
+		}
+		state.Lane = m.To
+		state.Position = m.To.Position()
+		state.Moving = false
+		if timeMs < m.EndMs {
+			// 引き延ばした移動は最短時間の移動に換算した経過時間で位置を求める
+			elapsedMs := (timeMs - m.StartMs) * t.profile.TravelMs(m.From, m.To) / (m.EndMs - m.StartMs)
+			travelled := t.profile.TravelledAt(m.From, m.To, elapsedMs)
+			if m.To < m.From {
+				travelled = -travelled
+			}
+			state.Position = m.From.Position() + travelled
+			state.Moving = true
+		}
+	}
+	for _, p := range t.presses[index(hand)] {
+		if p.StartMs <= timeMs && timeMs < p.EndMs {
+			state.Pressed = true
+			state.Actuators = append(state.Actuators, p.Actuator)
+		}
+	}
+	return state
+}
+
+// Changes は両手の状態変化を時刻順に返します
+// 同じ時刻では離す、押す、移動の終わり、移動の始まりの順で、その中では左手を先にします
+func (t *Timeline) Changes() []Change {
+	changes := []Change{}
+	for i, hand := range []CommandArm.Hand{CommandArm.Left, CommandArm.Right} {
+		for _, m := range t.moves[i] {
+			if m.EndMs <= m.StartMs {
+				continue
+			}
+			changes = append(changes,
+				Change{TimeMs: m.StartMs, Hand: hand, Kind: MoveStart, Lane: m.To, Source: m.Source},
+				Change{TimeMs: m.EndMs, Hand: hand, Kind: MoveEnd, Lane: m.To, Source: m.Source},
+			)
+		}
+		for _, p := range t.presses[i] {
+			changes = append(changes, Change{TimeMs: p.StartMs, Hand: hand, Kind: PressOn, Actuator: p.Actuator, Source: p.Source})
+			if !math.IsInf(p.EndMs, 1) {
+				changes = append(changes, Change{TimeMs: p.EndMs, Hand: hand, Kind: PressOff, Actuator: p.Actuator, Source: p.Source})
+			}
+		}
+	}
+	order := map[ChangeKind]int{PressOff: 0, PressOn: 1, MoveEnd: 2, MoveStart: 3}
+	sort.SliceStable(changes, func(a, b int) bool {
+		if changes[a].TimeMs != changes[b].TimeMs {
+			return changes[a].TimeMs < changes[b].TimeMs
+		}
+		if order[changes[a].Kind] != order[changes[b].Kind] {
+			return order[changes[a].Kind] < order[changes[b].Kind]
+		}
+		return changes[a].Hand < changes[b].Hand
+	})
+	return changes
+}
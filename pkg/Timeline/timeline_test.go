@@ -0,0 +1,143 @@
+package Timeline
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/ArmProfile"
+	"github.com/taniho0707/auto-sl-stage-tool/pkg/CommandArm"
+)
+
+func profile() ArmProfile.Profile {
+	p := ArmProfile.Default()
+	p.Home.Left = CommandArm.Lane1
+	p.Home.Right = CommandArm.Lane5
+	return p
+}
+
+func TestStateAt(t *testing.T) {
+	// 1C -> 5C の4レーンは 500ms (加速・減速 100ms ずつ)
+	left := []CommandArm.Command{
+		CommandArm.NewCommandMoveUntil(0, CommandArm.Left, CommandArm.Lane5, 500),
+		CommandArm.NewCommandSolenoid(500, CommandArm.Left, true),
+		CommandArm.NewCommandSolenoid(510, CommandArm.Left, false),
+	}
+	right := []CommandArm.Command{
+		CommandArm.NewCommandActuator(100, CommandArm.Right, 1, true),
+	}
+	timeline := New(left, right, profile())
+
+	t.Run("before the first command", func(t *testing.T) {
+		state := timeline.StateAt(-10)
+		assert.Equal(t, HandState{Position: 1, Lane: CommandArm.Lane1, Actuators: []int{}}, state.Left)
+		assert.Equal(t, HandState{Position: 5, Lane: CommandArm.Lane5, Actuators: []int{}}, state.Right)
+	})
+
+	t.Run("interpolated during a move", func(t *testing.T) {
+		state := timeline.StateAt(250)
+		assert.True(t, state.Left.Moving)
+		assert.Equal(t, CommandArm.Lane5, state.Left.Lane)
+		assert.InDelta(t, 3.0, state.Left.Position, 1e-9)
+		assert.InDelta(t, 1.125, timeline.StateAt(50).Left.Position, 1e-9)
+	})
+
+	t.Run("pressed after arriving", func(t *testing.T) {
+		state := timeline.StateAt(505)
+		assert.False(t, state.Left.Moving)
+		assert.Equal(t, 5.0, state.Left.Position)
+		assert.True(t, state.Left.Pressed)
+		assert.Equal(t, []int{0}, state.Left.Actuators)
+		assert.False(t, timeline.StateAt(510).Left.Pressed)
+	})
+
+	t.Run("press left open stays pressed", func(t *testing.T) {
+		state := timeline.StateAt(1e6)
+		assert.True(t, state.Right.Pressed)
+		assert.Equal(t, []int{1}, state.Right.Actuators)
+	})
+}
+
+func TestStretchedMove(t *testing.T) {
+	// スライドの中継のように、500ms で行ける 1C -> 5C を 1000ms かけて動く
+	left := []CommandArm.Command{
+		CommandArm.NewCommandSolenoid(0, CommandArm.Left, true),
+		CommandArm.NewCommandMoveUntil(0, CommandArm.Left, CommandArm.Lane5, 1000),
+		CommandArm.NewCommandSolenoid(1000, CommandArm.Left, false),
+	}
+	timeline := New(left, nil, profile())
+
+	moves := timeline.Moves(CommandArm.Left)
+	assert.Len(t, moves, 1)
+	assert.Equal(t, 1000.0, moves[0].EndMs)
+	assert.InDelta(t, 3.0, timeline.StateAt(500).Left.Position, 1e-9)
+	assert.InDelta(t, 1.125, timeline.StateAt(100).Left.Position, 1e-9)
+	assert.True(t, timeline.StateAt(900).Left.Moving)
+
+	// 最短時間で間に合わない期限は最短時間で着く
+	late := New([]CommandArm.Command{CommandArm.NewCommandMoveUntil(0, CommandArm.Left, CommandArm.Lane5, 300)}, nil, profile())
+	assert.Equal(t, 500.0, late.Moves(CommandArm.Left)[0].EndMs)
+}
+
+func TestMoves(t *testing.T) {
+	// 2つ目の移動は1つ目が着いてから動き始める
+	left := []CommandArm.Command{
+		CommandArm.NewCommandMove(0, CommandArm.Left, CommandArm.Lane5),
+		CommandArm.NewCommandMove(100, CommandArm.Left, CommandArm.Lane1),
+	}
+	moves := New(left, nil, profile()).Moves(CommandArm.Left)
+
+	assert.Len(t, moves, 2)
+	assert.Equal(t, 500.0, moves[0].EndMs)
+	assert.Equal(t, 100.0, moves[1].CommandMs)
+	assert.Equal(t, 500.0, moves[1].StartMs)
+	assert.Equal(t, 1000.0, moves[1].EndMs)
+	assert.Equal(t, CommandArm.Lane5, moves[1].From)
+	assert.InDelta(t, 3.0, New(left, nil, profile()).StateAt(750).Left.Position, 1e-9)
+}
+
+func TestPresses(t *testing.T) {
+	left := []CommandArm.Command{
+		CommandArm.NewCommandMove(0, CommandArm.Left, CommandArm.Lane5),
+		CommandArm.NewCommandSolenoid(100, CommandArm.Left, true),
+		CommandArm.NewCommandSolenoid(200, CommandArm.Left, false),
+		CommandArm.NewCommandSolenoid(600, CommandArm.Left, true),
+		CommandArm.NewCommandSolenoid(700, CommandArm.Left, false),
+	}
+	timeline := New(left, nil, profile())
+
+	presses := timeline.Presses(CommandArm.Left, math.Inf(-1), math.Inf(1))
+	assert.Len(t, presses, 2)
+	assert.Equal(t, Press{Hand: CommandArm.Left, StartMs: 100, EndMs: 200, Lane: CommandArm.Lane5, ReadyMs: 500}, presses[0])
+	assert.Equal(t, 600.0, presses[1].StartMs)
+
+	assert.Len(t, timeline.Presses(CommandArm.Left, 150, 300), 1)
+	assert.Len(t, timeline.Presses(CommandArm.Left, 200, 599), 0)
+	assert.Empty(t, timeline.Presses(CommandArm.Right, math.Inf(-1), math.Inf(1)))
+}
+
+func TestChanges(t *testing.T) {
+	left := []CommandArm.Command{
+		CommandArm.NewCommandMove(0, CommandArm.Left, CommandArm.Lane5),
+		CommandArm.NewCommandSolenoid(500, CommandArm.Left, true),
+		CommandArm.NewCommandSolenoid(510, CommandArm.Left, false),
+	}
+	right := []CommandArm.Command{
+		CommandArm.NewCommandSolenoid(500, CommandArm.Right, true),
+		CommandArm.NewCommandMove(600, CommandArm.Right, CommandArm.Lane5), // 既にいるので変化しない
+	}
+	changes := New(left, right, profile()).Changes()
+
+	kinds := []ChangeKind{}
+	hands := []CommandArm.Hand{}
+	times := []float64{}
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+		hands = append(hands, c.Hand)
+		times = append(times, c.TimeMs)
+	}
+	assert.Equal(t, []ChangeKind{MoveStart, PressOn, PressOn, MoveEnd, PressOff}, kinds)
+	assert.Equal(t, []CommandArm.Hand{CommandArm.Left, CommandArm.Left, CommandArm.Right, CommandArm.Left, CommandArm.Left}, hands)
+	assert.Equal(t, []float64{0, 500, 500, 500, 510}, times)
+}